@@ -0,0 +1,358 @@
+package lion
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BindConfig configures how Bind reads request bodies
+type BindConfig struct {
+	// MaxBodySize is the maximum number of bytes read from the request body
+	MaxBodySize int64
+	// MaxMemory is the maximum number of bytes of a multipart form stored in memory, the rest is stored on disk
+	MaxMemory int64
+	// DisallowUnknownFields makes JSON decoding fail if the body contains fields that are not in the destination struct
+	DisallowUnknownFields bool
+}
+
+// DefaultBindConfig returns the configuration used if none has been set with Router.ConfigureBinding
+func DefaultBindConfig() BindConfig {
+	return BindConfig{
+		MaxBodySize: 10 << 20, // 10 MB
+		MaxMemory:   32 << 20, // 32 MB
+	}
+}
+
+// ConfigureBinding sets the configuration used by Bind for the requests handled by this router and its subrouters
+func (r *Router) ConfigureBinding(cfg BindConfig) {
+	r.bindConfig = &cfg
+}
+
+func (r *Router) bindingConfig() BindConfig {
+	if rr := r.nearest(func(rr *Router) bool { return rr.bindConfig != nil }); rr != nil {
+		return *rr.bindConfig
+	}
+	return DefaultBindConfig()
+}
+
+// Binding sources used by BindError
+const (
+	BindSourceBody   = "body"
+	BindSourceForm   = "form"
+	BindSourceParam  = "param"
+	BindSourceQuery  = "query"
+	BindSourceHeader = "header"
+)
+
+// ErrUnsupportedMediaType is used by BindError when the request's Content-Type cannot be decoded
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// BindError is returned by Bind when the request cannot be bound.
+// It implements HTTPError: 413 if the body is too large, 415 if the Content-Type is not supported and 400 otherwise.
+type BindError struct {
+	Source string // One of the BindSource* constants
+	Field  string // Name of the struct field, empty for body decoding errors
+	Name   string // Name of the param, query parameter, header or form field
+	Value  string
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("cannot bind %s: %s", e.Source, e.Err)
+	}
+	return fmt.Sprintf("cannot bind %s %q to field %s: %s", e.Source, e.Name, e.Field, e.Err)
+}
+
+// Status returns the http status code corresponding to the error
+func (e *BindError) Status() int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case e.Err == ErrUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case errors.As(e.Err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// Bind decodes the request of c into v which should be a pointer to a struct.
+//
+// The body is decoded according to the Content-Type header:
+// 	application/json				json tags
+// 	application/xml, text/xml			xml tags
+// 	application/x-www-form-urlencoded		form tags
+// 	multipart/form-data				form tags, *multipart.FileHeader fields receive files
+//
+// Then, fields are populated from the url params, query string and headers using the param, query and header tags:
+//		 type UpdateUser struct {
+//		 	ID      int    `param:"id"`
+//		 	Notify  bool   `query:"notify"`
+//		 	TraceID string `header:"X-Trace-Id"`
+//		 	Name    string `json:"name" form:"name"`
+//		 }
+//
+//		 var u UpdateUser
+//		 err := lion.Bind(c, &u)
+//
+// Supported field types are strings, booleans, numbers, time.Duration, time.Time (RFC 3339), encoding.TextUnmarshaler,
+// slices and pointers of those.
+// The returned error is a *BindError.
+//
+// Once bound, v is checked with Validate. If a validate tag is not satisfied, a *ValidationError is returned.
+func Bind(c Context, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panicl("Bind expects a non nil pointer but got %T", v)
+	}
+
+	if err := bindBody(c, v); err != nil {
+		return err
+	}
+
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	req := c.Request()
+	query := req.URL.Query()
+	err := bindStruct(rv, func(field reflect.StructField) (string, string, []string, bool) {
+		if name := field.Tag.Get("param"); name != "" {
+			val, ok := c.ParamOk(name)
+			return BindSourceParam, name, []string{val}, ok
+		}
+		if name := field.Tag.Get("query"); name != "" {
+			vals, ok := query[name]
+			return BindSourceQuery, name, vals, ok
+		}
+		if name := field.Tag.Get("header"); name != "" {
+			vals, ok := req.Header[http.CanonicalHeaderKey(name)]
+			return BindSourceHeader, name, vals, ok
+		}
+		return "", "", nil, false
+	})
+	if err != nil {
+		return err
+	}
+
+	return Validate(v)
+}
+
+func bindBody(c Context, v interface{}) error {
+	req := c.Request()
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+
+	cfg := DefaultBindConfig()
+	if ctx, ok := c.(*ctx); ok && ctx.router != nil {
+		cfg = ctx.router.bindingConfig()
+	}
+
+	ctype := req.Header.Get("Content-Type")
+	mediatype, _, err := mime.ParseMediaType(ctype)
+	if err != nil && ctype != "" {
+		return &BindError{Source: BindSourceBody, Err: ErrUnsupportedMediaType}
+	}
+
+	if cfg.MaxBodySize > 0 {
+		req.Body = http.MaxBytesReader(c, req.Body, cfg.MaxBodySize)
+	}
+
+	switch {
+	case mediatype == "application/json" || strings.HasSuffix(mediatype, "+json"):
+		dec := json.NewDecoder(req.Body)
+		if cfg.DisallowUnknownFields {
+			dec.DisallowUnknownFields()
+		}
+		err = dec.Decode(v)
+	case mediatype == "application/xml" || mediatype == "text/xml" || strings.HasSuffix(mediatype, "+xml"):
+		err = xml.NewDecoder(req.Body).Decode(v)
+	case mediatype == "application/x-www-form-urlencoded":
+		if err = req.ParseForm(); err == nil {
+			return bindForm(v, req.PostForm, nil)
+		}
+	case mediatype == "multipart/form-data":
+		if err = req.ParseMultipartForm(cfg.MaxMemory); err == nil {
+			return bindForm(v, req.MultipartForm.Value, req.MultipartForm.File)
+		}
+	case ctype == "":
+		// Nothing to decode
+		return nil
+	default:
+		err = ErrUnsupportedMediaType
+	}
+
+	if err != nil {
+		return &BindError{Source: BindSourceBody, Err: err}
+	}
+	return nil
+}
+
+func bindForm(v interface{}, values map[string][]string, files map[string][]*multipart.FileHeader) error {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return bindStruct(rv, func(field reflect.StructField) (string, string, []string, bool) {
+		name := field.Tag.Get("form")
+		if name == "" {
+			return "", "", nil, false
+		}
+		vals, ok := values[name]
+		return BindSourceForm, name, vals, ok
+	}, fileBinder(files))
+}
+
+// fileBinder sets *multipart.FileHeader and []*multipart.FileHeader fields
+func fileBinder(files map[string][]*multipart.FileHeader) func(field reflect.StructField, fv reflect.Value) bool {
+	return func(field reflect.StructField, fv reflect.Value) bool {
+		name := field.Tag.Get("form")
+		if name == "" || len(files[name]) == 0 {
+			return false
+		}
+		switch fv.Interface().(type) {
+		case *multipart.FileHeader:
+			fv.Set(reflect.ValueOf(files[name][0]))
+			return true
+		case []*multipart.FileHeader:
+			fv.Set(reflect.ValueOf(files[name]))
+			return true
+		}
+		return false
+	}
+}
+
+// bindStruct walks through the fields of rv and sets the values returned by lookup
+func bindStruct(rv reflect.Value, lookup func(reflect.StructField) (source, name string, vals []string, ok bool), custom ...func(reflect.StructField, reflect.Value) bool) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.PkgPath != "" && !field.Anonymous { // unexported
+			continue
+		}
+
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			if err := bindStruct(fv, lookup, custom...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		handled := false
+		for _, fn := range custom {
+			if fn(field, fv) {
+				handled = true
+				break
+			}
+		}
+		if handled {
+			continue
+		}
+
+		source, name, vals, ok := lookup(field)
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if err := setField(fv, vals); err != nil {
+			return &BindError{Source: source, Field: field.Name, Name: name, Value: vals[0], Err: err}
+		}
+	}
+	return nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+func setField(fv reflect.Value, vals []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, vals[0])
+}
+
+func setValue(fv reflect.Value, val string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), val)
+	}
+
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
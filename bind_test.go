@@ -0,0 +1,188 @@
+package lion
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindPagination struct {
+	Page  int           `query:"page"`
+	Sort  *string       `query:"sort"`
+	Tags  []string      `query:"tag"`
+	Since time.Duration `query:"since"`
+}
+
+type bindUser struct {
+	bindPagination
+	ID      int64                 `param:"id"`
+	TraceID string                `header:"X-Trace-Id"`
+	Name    string                `json:"name" xml:"name" form:"name"`
+	Age     uint8                 `json:"age" xml:"age" form:"age"`
+	Admin   bool                  `json:"admin" xml:"admin" form:"admin"`
+	Avatar  *multipart.FileHeader `form:"avatar"`
+}
+
+func TestBind(t *testing.T) {
+	sort := "asc"
+	expectedParams := bindUser{
+		bindPagination: bindPagination{Page: 2, Sort: &sort, Tags: []string{"a", "b"}, Since: time.Hour},
+		ID:             42,
+		TraceID:        "trace",
+	}
+
+	tests := []struct {
+		name     string
+		ctype    string
+		body     string
+		expected bindUser
+	}{
+		{name: "no body"},
+		{name: "json", ctype: "application/json; charset=utf-8", body: `{"name":"batman","age":42,"admin":true}`, expected: bindUser{Name: "batman", Age: 42, Admin: true}},
+		{name: "json suffix", ctype: "application/vnd.api+json", body: `{"name":"batman"}`, expected: bindUser{Name: "batman"}},
+		{name: "xml", ctype: "application/xml", body: `<user><name>batman</name><age>42</age></user>`, expected: bindUser{Name: "batman", Age: 42}},
+		{name: "form", ctype: "application/x-www-form-urlencoded", body: "name=batman&age=42&admin=true", expected: bindUser{Name: "batman", Age: 42, Admin: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got bindUser
+			err := bindRequest(New(), test.ctype, test.body, &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := test.expected
+			want.bindPagination = expectedParams.bindPagination
+			want.ID = expectedParams.ID
+			want.TraceID = expectedParams.TraceID
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %+v but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestBindMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", "batman")
+	fw, _ := mw.CreateFormFile("avatar", "bat.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+
+	var got bindUser
+	if err := bindRequest(New(), mw.FormDataContentType(), buf.String(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "batman" {
+		t.Errorf("Expected name 'batman' but got '%s'", got.Name)
+	}
+	if got.Avatar == nil || got.Avatar.Filename != "bat.png" {
+		t.Fatalf("Avatar should be bound")
+	}
+	f, _ := got.Avatar.Open()
+	content, _ := ioutil.ReadAll(f)
+	if string(content) != "png" {
+		t.Errorf("Unexpected file content: %s", content)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	strict := New()
+	strict.ConfigureBinding(BindConfig{MaxBodySize: 16, DisallowUnknownFields: true})
+
+	tests := []struct {
+		name     string
+		router   *Router
+		ctype    string
+		body     string
+		query    string
+		status   int
+		expected string
+	}{
+		{name: "invalid json", router: New(), ctype: "application/json", body: `{"name":`, status: http.StatusBadRequest},
+		{name: "unsupported", router: New(), ctype: "text/csv", body: "name,batman", status: http.StatusUnsupportedMediaType, expected: "cannot bind body: unsupported media type"},
+		{name: "too large", router: strict, ctype: "application/json", body: `{"name":"batman the dark knight"}`, status: http.StatusRequestEntityTooLarge},
+		{name: "unknown field", router: strict, ctype: "application/json", body: `{"cape":1}`, status: http.StatusBadRequest},
+		{name: "invalid form", router: New(), ctype: "application/x-www-form-urlencoded", body: "age=old", status: http.StatusBadRequest},
+		{name: "invalid query", router: New(), query: "page=two", status: http.StatusBadRequest, expected: `cannot bind query "page" to field Page: strconv.ParseInt: parsing "two": invalid syntax`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got bindUser
+			err := bindRequestWithQuery(test.router, test.ctype, test.body, test.query, &got)
+			berr, ok := err.(*BindError)
+			if !ok {
+				t.Fatalf("Expected a *BindError but got %T", err)
+			}
+			if berr.Status() != test.status {
+				t.Errorf("Expected status %d but got %d", test.status, berr.Status())
+			}
+			if test.expected != "" && berr.Error() != test.expected {
+				t.Errorf("Expected '%s' but got '%s'", test.expected, berr.Error())
+			}
+		})
+	}
+}
+
+func TestBindSubrouterConfig(t *testing.T) {
+	l := New()
+	l.ConfigureBinding(BindConfig{MaxBodySize: 4})
+	api := l.Group("/api")
+	api.ConfigureBinding(DefaultBindConfig())
+
+	handler := func(c Context) {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := Bind(c, &v); err != nil {
+			c.Error(err)
+			return
+		}
+		c.String("%s", v.Name)
+	}
+	l.Post("/users", wrap(handler))
+	api.Post("/users", wrap(handler))
+
+	body := `{"name":"batman"}`
+	for path, status := range map[string]int{"/users": http.StatusRequestEntityTooLarge, "/api/users": http.StatusOK} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: expected status %d but got %d", path, status, w.Code)
+		}
+	}
+}
+
+func bindRequest(l *Router, ctype, body string, v interface{}) error {
+	return bindRequestWithQuery(l, ctype, body, "page=2&sort=asc&tag=a&tag=b&since=1h", v)
+}
+
+func bindRequestWithQuery(l *Router, ctype, body, query string, v interface{}) (err error) {
+	l.Post("/users/:id", wrap(func(c Context) {
+		err = Bind(c, v)
+	}))
+
+	req := httptest.NewRequest("POST", "/users/42?"+query, strings.NewReader(body))
+	if body == "" {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	if ctype != "" {
+		req.Header.Set("Content-Type", ctype)
+	}
+	req.Header.Set("X-Trace-Id", "trace")
+	l.ServeHTTP(httptest.NewRecorder(), req)
+	return
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/celrenheit/lion/internal/matcher"
 )
@@ -70,14 +72,18 @@ type ctx struct {
 
 	parent context.Context
 	req    *http.Request
+	router *Router
 
 	params []parameter
 
 	code          int
 	statusWritten bool
 
-	tags          matcher.Tags
-	searchHistory []string
+	tags matcher.Tags
+
+	// trace records the matching of the request if it is explained
+	trace      *MatchTrace
+	tracePhase string
 }
 
 // newContext creates a new context instance
@@ -96,7 +102,6 @@ func newContextWithResReq(c context.Context, w http.ResponseWriter, r *http.Requ
 		ResponseWriter: w,
 		req:            r,
 		tags:           make([]string, 1),
-		searchHistory:  make([]string, 0, 20), // Preallocate enough capacity. TODO: Make it depend on max nodes
 	}
 }
 
@@ -140,6 +145,7 @@ func (c *ctx) ParamOk(key string) (string, bool) {
 func (c *ctx) Clone() Context {
 	nc := newContext()
 	nc.parent = c.parent
+	nc.router = c.router
 	nc.params = make([]parameter, len(c.params), cap(c.params))
 	copy(nc.params, c.params)
 
@@ -150,10 +156,6 @@ func (c *ctx) Clone() Context {
 	return nc
 }
 
-func (c *ctx) SearchHistory() []string {
-	return c.searchHistory
-}
-
 ///////////// REQUEST UTILS ////////////////
 
 func (c *ctx) Request() *http.Request {
@@ -178,6 +180,127 @@ func (c *ctx) GetHeader(key string) string {
 
 ///////////// REQUEST UTILS ////////////////
 
+///////////// TYPED PARAMS ////////////////
+
+// The following helpers return the typed values of url params and query string parameters.
+// The returned error is a *ParamError.
+
+// ParamInt returns the value of a url param as an int
+// 	id, err := lion.ParamInt(c, "id")
+func ParamInt(c Context, key string) (int, error) {
+	val, ok := c.ParamOk(key)
+	i, err := parseInt(paramSourceURL, key, val, ok, strconv.IntSize)
+	return int(i), err
+}
+
+// ParamInt64 returns the value of a url param as an int64
+func ParamInt64(c Context, key string) (int64, error) {
+	val, ok := c.ParamOk(key)
+	return parseInt(paramSourceURL, key, val, ok, 64)
+}
+
+// ParamUUID returns the value of a url param parsed as a uuid (e.g. 6ba7b810-9dad-11d1-80b4-00c04fd430c8)
+func ParamUUID(c Context, key string) (id [16]byte, err error) {
+	val, ok := c.ParamOk(key)
+	if !ok {
+		return id, newParamError(paramSourceURL, key, val, "uuid", ErrParamMissing)
+	}
+	if !isUUID(val) {
+		return id, newParamError(paramSourceURL, key, val, "uuid", nil)
+	}
+
+	j := 0
+	for i := 0; i < len(val); i += 2 {
+		if val[i] == '-' {
+			i++
+		}
+		b, _ := strconv.ParseUint(val[i:i+2], 16, 8)
+		id[j] = byte(b)
+		j++
+	}
+	return id, nil
+}
+
+// ParamTime returns the value of a url param parsed as a time.Time using the layout provided
+func ParamTime(c Context, key, layout string) (time.Time, error) {
+	val, ok := c.ParamOk(key)
+	if !ok {
+		return time.Time{}, newParamError(paramSourceURL, key, val, "time", ErrParamMissing)
+	}
+	t, err := time.Parse(layout, val)
+	if err != nil {
+		return t, newParamError(paramSourceURL, key, val, "time", err)
+	}
+	return t, nil
+}
+
+// QueryAll returns all the values of a query string parameter
+func QueryAll(c Context, name string) []string {
+	return c.Request().URL.Query()[name]
+}
+
+// QueryDefault returns the first value of a query string parameter or def if it is not set or empty
+func QueryDefault(c Context, name, def string) string {
+	if v := c.Query(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// QueryInt returns the first value of a query string parameter as an int
+func QueryInt(c Context, name string) (int, error) {
+	val, ok := firstQuery(c, name)
+	i, err := parseInt(paramSourceQuery, name, val, ok, strconv.IntSize)
+	return int(i), err
+}
+
+// QueryInt64 returns the first value of a query string parameter as an int64
+func QueryInt64(c Context, name string) (int64, error) {
+	val, ok := firstQuery(c, name)
+	return parseInt(paramSourceQuery, name, val, ok, 64)
+}
+
+// QueryBool returns the first value of a query string parameter as a bool.
+// It accepts the values accepted by strconv.ParseBool. A parameter present without a value (?debug) is true.
+func QueryBool(c Context, name string) (bool, error) {
+	val, ok := firstQuery(c, name)
+	if !ok {
+		return false, newParamError(paramSourceQuery, name, "", "bool", ErrParamMissing)
+	}
+	if val == "" {
+		return true, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, newParamError(paramSourceQuery, name, val, "bool", nil)
+	}
+	return b, nil
+}
+
+// firstQuery returns the first value of a query string parameter and whether it is present
+func firstQuery(c Context, name string) (string, bool) {
+	vals, ok := c.Request().URL.Query()[name]
+	if !ok || len(vals) == 0 {
+		return "", ok
+	}
+	return vals[0], true
+}
+
+// parseInt parses val as an integer that fits in bitSize bits
+func parseInt(source, name, val string, ok bool, bitSize int) (int64, error) {
+	if !ok {
+		return 0, newParamError(source, name, val, "int", ErrParamMissing)
+	}
+	i, err := strconv.ParseInt(val, 10, bitSize)
+	if err != nil {
+		return 0, newParamError(source, name, val, "int", nil)
+	}
+	return i, nil
+}
+
+///////////// TYPED PARAMS ////////////////
+
 ///////////// RESPONSE MODIFIERS /////////////
 
 // WithStatus sets the status code for the current request.
@@ -189,7 +312,12 @@ func (c *ctx) WithStatus(code int) Context {
 
 func (c *ctx) writeHeader() {
 	if !c.isStatusWritten() {
-		c.WriteHeader(c.code)
+		// A status must be written before the body, 200 OK is used if none has been set with WithStatus
+		code := c.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		c.WriteHeader(code)
 		c.statusWritten = true
 	}
 }
@@ -227,11 +355,20 @@ func (c *ctx) String(format string, a ...interface{}) error {
 }
 
 func (c *ctx) Error(err error) error {
+	if p, ok := err.(*Problem); ok {
+		return p.render(c)
+	}
+	if c.router != nil && c.router.problemDetailsEnabled() {
+		return toProblem(err).render(c)
+	}
+	if verr, ok := err.(*ValidationError); ok {
+		return verr.render(c)
+	}
 	if herr, ok := err.(HTTPError); ok {
 		return c.WithStatus(herr.Status()).
-			String(err.Error())
+			String("%s", err.Error())
 	}
-	return c.String(err.Error())
+	return c.String("%s", err.Error())
 }
 
 func (c *ctx) XML(data interface{}) error {
@@ -278,10 +415,11 @@ func (c *ctx) Reset() {
 	c.params = c.params[:0]
 	c.parent = nil
 	c.req = nil
+	c.router = nil
 	c.ResponseWriter = nil
 	c.code = 0
 	c.statusWritten = false
-	c.searchHistory = c.searchHistory[:0]
+	c.trace = nil
 }
 
 func (c *ctx) Remove(key string) {
@@ -353,6 +491,10 @@ var (
 	ErrorNotFound HTTPError = httpError{http.StatusNotFound}
 	// ErrorMethodNotAllowed returns a MethodNotAllowed response with the corresponding body
 	ErrorMethodNotAllowed HTTPError = httpError{http.StatusMethodNotAllowed}
+	// ErrorNotAcceptable returns a NotAcceptable response with the corresponding body
+	ErrorNotAcceptable HTTPError = httpError{http.StatusNotAcceptable}
+	// ErrorUnsupportedMediaType returns a UnsupportedMediaType response with the corresponding body
+	ErrorUnsupportedMediaType HTTPError = httpError{http.StatusUnsupportedMediaType}
 
 	// 5xx
 
@@ -369,3 +511,58 @@ func (e httpError) Error() string {
 func (e httpError) Status() int {
 	return e.code
 }
+
+// PanicError is passed to the ErrorHandler when an error-returning contextual handler panics.
+// It implements HTTPError with a 500 Internal Server Error status.
+type PanicError struct {
+	Value interface{} // Value passed to panic
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Status returns http.StatusInternalServerError
+func (e *PanicError) Status() int {
+	return http.StatusInternalServerError
+}
+
+// ErrParamMissing is used by ParamError when a param or a query string parameter is not set
+var ErrParamMissing = errors.New("missing")
+
+const (
+	paramSourceURL   = "url parameter"
+	paramSourceQuery = "query parameter"
+)
+
+// ParamError is returned by the typed param and query helpers such as ParamInt when a value is missing or invalid.
+// It implements HTTPError with a 400 Bad Request status so it can be passed directly to Context.Error:
+//		 id, err := lion.ParamInt(c, "id")
+//		 if err != nil {
+//		 	c.Error(err) // 400: invalid url parameter "id": "abc" is not a valid int
+//		 	return
+//		 }
+type ParamError struct {
+	Source string // "url parameter" or "query parameter"
+	Name   string
+	Value  string
+	Type   string
+	Err    error // ErrParamMissing if the value is not set
+}
+
+func newParamError(source, name, value, typ string, err error) *ParamError {
+	return &ParamError{Source: source, Name: name, Value: value, Type: typ, Err: err}
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == ErrParamMissing {
+		return fmt.Sprintf("missing %s %q", e.Source, e.Name)
+	}
+	return fmt.Sprintf("invalid %s %q: %q is not a valid %s", e.Source, e.Name, e.Value, e.Type)
+}
+
+// Status returns http.StatusBadRequest
+func (e *ParamError) Status() int {
+	return http.StatusBadRequest
+}
@@ -2,6 +2,7 @@ package lion
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -180,7 +181,7 @@ func TestContextRender(t *testing.T) {
 						err = c.XML(test.input)
 						ctype = contentTypeXML
 					case "string":
-						err = c.String(test.input.(string))
+						err = c.String("%s", test.input.(string))
 						ctype = contentTypeTextPlain
 					default:
 						panicl("unsupported test %s", dtype)
@@ -272,6 +273,23 @@ func TestWithStatus(t *testing.T) {
 	}
 }
 
+func TestDefaultStatus(t *testing.T) {
+	c, w := newTestCtx()
+	if err := c.String("ok"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Expected 200 ok but got %d %s", w.Code, w.Body.String())
+	}
+
+	// Error messages are written as is, not as format strings
+	c, w = newTestCtx()
+	c.Error(errors.New("100% failed"))
+	if w.Code != http.StatusOK || w.Body.String() != "100% failed" {
+		t.Errorf("Expected 200 100%% failed but got %d %s", w.Code, w.Body.String())
+	}
+}
+
 func TestWithHeader(t *testing.T) {
 	c, w := newTestCtx()
 
@@ -321,6 +339,80 @@ func TestRedirect(t *testing.T) {
 	}
 }
 
+func TestContextTypedParams(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/hello?page=2&bad=abc&debug&verbose=false&tag=a&tag=b", nil)
+	c := newContextWithResReq(context.Background(), w, r)
+	c.AddParam("id", "42")
+	c.AddParam("name", "batman")
+	c.AddParam("ref", "6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	c.AddParam("day", "2016-11-30")
+	c.AddParam("huge", "9223372036854775808")
+
+	if id, err := ParamInt(c, "id"); err != nil || id != 42 {
+		t.Errorf("ParamInt: got %d, %v", id, err)
+	}
+	if id, err := ParamInt64(c, "id"); err != nil || id != 42 {
+		t.Errorf("ParamInt64: got %d, %v", id, err)
+	}
+	if ref, err := ParamUUID(c, "ref"); err != nil || fmt.Sprintf("%x", ref) != "6ba7b8109dad11d180b400c04fd430c8" {
+		t.Errorf("ParamUUID: got %x, %v", ref, err)
+	}
+	if day, err := ParamTime(c, "day", "2006-01-02"); err != nil || day.Day() != 30 {
+		t.Errorf("ParamTime: got %s, %v", day, err)
+	}
+	if page, err := QueryInt(c, "page"); err != nil || page != 2 {
+		t.Errorf("QueryInt: got %d, %v", page, err)
+	}
+	if debug, err := QueryBool(c, "debug"); err != nil || !debug {
+		t.Errorf("QueryBool: got %v, %v", debug, err)
+	}
+	if verbose, err := QueryBool(c, "verbose"); err != nil || verbose {
+		t.Errorf("QueryBool: got %v, %v", verbose, err)
+	}
+	if tags := QueryAll(c, "tag"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("QueryAll: got %v", tags)
+	}
+	if sort := QueryDefault(c, "sort", "asc"); sort != "asc" {
+		t.Errorf("QueryDefault: got %s", sort)
+	}
+
+	errorTests := []struct {
+		fn       func() error
+		expected string
+	}{
+		{func() error { _, err := ParamInt(c, "name"); return err }, `invalid url parameter "name": "batman" is not a valid int`},
+		{func() error { _, err := ParamInt(c, "huge"); return err }, `invalid url parameter "huge": "9223372036854775808" is not a valid int`},
+		{func() error { _, err := ParamInt(c, "unknown"); return err }, `missing url parameter "unknown"`},
+		{func() error { _, err := ParamUUID(c, "name"); return err }, `invalid url parameter "name": "batman" is not a valid uuid`},
+		{func() error { _, err := ParamTime(c, "name", "2006"); return err }, `invalid url parameter "name": "batman" is not a valid time`},
+		{func() error { _, err := QueryInt(c, "bad"); return err }, `invalid query parameter "bad": "abc" is not a valid int`},
+		{func() error { _, err := QueryInt64(c, "unknown"); return err }, `missing query parameter "unknown"`},
+		{func() error { _, err := QueryBool(c, "bad"); return err }, `invalid query parameter "bad": "abc" is not a valid bool`},
+	}
+
+	for _, test := range errorTests {
+		err := test.fn()
+		perr, ok := err.(*ParamError)
+		if !ok {
+			t.Errorf("Expected a *ParamError but got %T", err)
+			continue
+		}
+		if perr.Error() != test.expected {
+			t.Errorf("Expected '%s' but got '%s'", test.expected, perr.Error())
+		}
+	}
+
+	_, err := ParamInt(c, "name")
+	c.Error(err)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+	if got := w.Body.String(); got != `invalid url parameter "name": "batman" is not a valid int` {
+		t.Errorf("Unexpected body: %s", got)
+	}
+}
+
 func newTestCtx() (c *ctx, w *httptest.ResponseRecorder) {
 	w = httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/hello", nil)
@@ -0,0 +1,97 @@
+package lion
+
+import (
+	"net/http"
+	"time"
+)
+
+// Deprecation describes a deprecated route
+type Deprecation struct {
+	// Sunset is the date after which the route will stop responding. It is optional.
+	Sunset time.Time
+	// Link is the url of the successor version. It is optional.
+	Link string
+}
+
+// deprecatedResource allows a Resource or a Module to declare that it is deprecated
+type deprecatedResource interface {
+	Deprecated() (sunset time.Time, link string)
+}
+
+func newDeprecation(sunset time.Time, link string) *Deprecation {
+	return &Deprecation{Sunset: sunset, Link: link}
+}
+
+func (r *route) Deprecated(sunset time.Time, link string) Route {
+	r.deprecation = newDeprecation(sunset, link)
+	r.rebuild()
+	return r
+}
+
+func (r *route) Deprecation() *Deprecation {
+	if r.deprecation != nil {
+		return r.deprecation
+	}
+	for _, method := range r.Methods() {
+		for _, rh := range r.variants[method] {
+			if rh.deprecation != nil {
+				return rh.deprecation
+			}
+		}
+	}
+	return nil
+}
+
+// Deprecated marks the routes registered on this router and its subrouters after this call as deprecated.
+// See Route.Deprecated.
+func (r *Router) Deprecated(sunset time.Time, link string) {
+	r.deprecation = newDeprecation(sunset, link)
+}
+
+func (r *Router) deprecationFor() *Deprecation {
+	if rr := r.nearest(func(rr *Router) bool { return rr.deprecation != nil }); rr != nil {
+		return rr.deprecation
+	}
+	return nil
+}
+
+// OnDeprecated sets a function called each time a deprecated route registered on this router or its subrouters is requested.
+// It can be used to log the remaining callers:
+// 	l.OnDeprecated(func(route lion.Route, r *http.Request) {
+// 		log.Printf("deprecated route %s called by %s", route.Pattern(), r.UserAgent())
+// 	})
+func (r *Router) OnDeprecated(fn func(Route, *http.Request)) {
+	r.deprecationHook = fn
+}
+
+func (r *Router) deprecationHookFor() func(Route, *http.Request) {
+	if rr := r.nearest(func(rr *Router) bool { return rr.deprecationHook != nil }); rr != nil {
+		return rr.deprecationHook
+	}
+	return nil
+}
+
+// deprecationHandler adds the Deprecation, Sunset and Link headers and calls the deprecation hook
+type deprecationHandler struct {
+	route       *route
+	router      *Router
+	deprecation *Deprecation
+	next        http.Handler
+}
+
+func (h deprecationHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+	header.Set("Deprecation", "true")
+	if !h.deprecation.Sunset.IsZero() {
+		header.Set("Sunset", h.deprecation.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if h.deprecation.Link != "" {
+		header.Add("Link", "<"+h.deprecation.Link+`>; rel="successor-version"`)
+	}
+
+	if hook := h.router.deprecationHookFor(); hook != nil {
+		hook(h.route, req)
+	}
+
+	h.next.ServeHTTP(w, req)
+}
@@ -0,0 +1,83 @@
+package lion
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/celrenheit/htest"
+)
+
+var testSunset = time.Date(2017, 6, 30, 23, 59, 59, 0, time.UTC)
+
+type deprecatedResourceType struct{}
+
+func (deprecatedResourceType) Get(c Context) { c.String("old") }
+
+func (deprecatedResourceType) Deprecated() (time.Time, string) {
+	return testSunset, ""
+}
+
+func TestDeprecatedRoute(t *testing.T) {
+	var hits []string
+
+	l := New()
+	l.OnDeprecated(func(route Route, r *http.Request) {
+		hits = append(hits, r.Method+" "+route.Pattern())
+	})
+
+	old := l.Get("/old", fakeHandlerWithBody("old")).
+		Deprecated(testSunset, "https://example.com/new")
+	old.WithMethod("POST", fakeHandlerWithBody("old"))
+	l.Get("/new", fakeHandlerWithBody("new"))
+	l.Resource("/resource", deprecatedResourceType{})
+
+	test := htest.New(t, l)
+	test.Get("/old").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Deprecation", "true").
+		ExpectHeader("Sunset", "Fri, 30 Jun 2017 23:59:59 GMT").
+		ExpectHeader("Link", `<https://example.com/new>; rel="successor-version"`)
+	test.Post("/old").Do().
+		ExpectHeader("Deprecation", "true")
+	test.Get("/new").Do().
+		ExpectHeader("Deprecation", "")
+	test.Get("/resource").Do().
+		ExpectBody("old").
+		ExpectHeader("Deprecation", "true").
+		ExpectHeader("Link", "")
+
+	if len(hits) != 3 || hits[0] != "GET /old" || hits[1] != "POST /old" || hits[2] != "GET /resource" {
+		t.Errorf("Unexpected hook calls: %v", hits)
+	}
+
+	var deprecated []string
+	for _, route := range l.Routes() {
+		if dep := route.Deprecation(); dep != nil {
+			deprecated = append(deprecated, route.Pattern())
+		}
+	}
+	if len(deprecated) != 2 {
+		t.Errorf("Expected 2 deprecated routes but got %v", deprecated)
+	}
+}
+
+type deprecatedModule struct{}
+
+func (deprecatedModule) Base() string { return "/v1" }
+func (deprecatedModule) Routes(r *Router) {
+	r.GET("/users", func(c Context) {})
+}
+func (deprecatedModule) Deprecated() (time.Time, string) {
+	return time.Time{}, "/v2"
+}
+
+func TestDeprecatedModule(t *testing.T) {
+	l := New()
+	l.Module(deprecatedModule{})
+
+	htest.New(t, l).Get("/v1/users").Do().
+		ExpectHeader("Deprecation", "true").
+		ExpectHeader("Sunset", "").
+		ExpectHeader("Link", `</v2>; rel="successor-version"`)
+}
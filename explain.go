@@ -0,0 +1,219 @@
+package lion
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/celrenheit/lion/internal/matcher"
+)
+
+// Results of a MatchTrace
+const (
+	MatchFound                = "matched"
+	MatchNotFound             = "not found"
+	MatchRedirect             = "redirect"
+	MatchMethodNotAllowed     = "method not allowed"
+	MatchAutomaticOptions     = "automatic options"
+	MatchNotAcceptable        = "not acceptable"
+	MatchUnsupportedMediaType = "unsupported media type"
+)
+
+// MatchTrace describes how a request was matched. It is returned by Router.Explain.
+//
+// It can be printed in a human-readable form with String or encoded to JSON.
+type MatchTrace struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	Path   string `json:"path"`
+
+	// Steps are the steps of the matching in the order they happened
+	Steps []MatchStep `json:"steps"`
+
+	// Result is one of MatchFound, MatchNotFound, MatchRedirect, MatchMethodNotAllowed, MatchAutomaticOptions,
+	// MatchNotAcceptable or MatchUnsupportedMediaType
+	Result string `json:"result"`
+	// Pattern and Name are set if a route was found
+	Pattern string `json:"pattern,omitempty"`
+	Name    string `json:"name,omitempty"`
+	// Location is set for redirects
+	Location string            `json:"location,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// MatchStep is a step of a MatchTrace
+type MatchStep struct {
+	// Matcher is "host" for the steps matching the host and "path" otherwise
+	Matcher string `json:"matcher"`
+	// Kind is static, param, wildcard, remove, tsr or match for the steps in the tree of routes
+	// and clean, case, method or predicates for the decisions of the router
+	Kind string `json:"kind"`
+	// Node is the pattern of the node of the tree
+	Node   string `json:"node,omitempty"`
+	Search string `json:"search,omitempty"`
+	Param  string `json:"param,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// String returns a human-readable form of the trace
+func (t MatchTrace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s%s\n", t.Method, t.Host, t.Path)
+	for _, s := range t.Steps {
+		fmt.Fprintf(&b, "  %-4s %-10s", s.Matcher, s.Kind)
+		if s.Node != "" {
+			fmt.Fprintf(&b, " %s", s.Node)
+		}
+		if s.Param != "" {
+			fmt.Fprintf(&b, " %s=%q", s.Param, s.Value)
+		} else if s.Value != "" {
+			fmt.Fprintf(&b, " %s", s.Value)
+		}
+		if s.Search != "" {
+			fmt.Fprintf(&b, " (search %q)", s.Search)
+		}
+		if s.Detail != "" {
+			fmt.Fprintf(&b, " %s", s.Detail)
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("=> " + t.Summary())
+	return b.String()
+}
+
+// Summary returns the result of the trace in one line
+func (t MatchTrace) Summary() string {
+	s := t.Result
+	if t.Pattern != "" {
+		s += " " + t.Pattern
+	}
+	if t.Name != "" {
+		s += " (" + t.Name + ")"
+	}
+	if t.Location != "" {
+		s += " to " + t.Location
+	}
+	if len(t.Params) > 0 {
+		keys := make([]string, 0, len(t.Params))
+		for k := range t.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			keys[i] = k + "=" + t.Params[k]
+		}
+		s += " " + strings.Join(keys, " ")
+	}
+	return s
+}
+
+// Explain returns how the router matches req without serving it.
+// It records the host matching, each node of the tree visited, the params captured and removed when backtracking,
+// the trailing slash, path cleaning and case decisions and the lookup of the method:
+// 	trace := l.Explain(httptest.NewRequest("GET", "/users/42", nil))
+// 	fmt.Println(trace)
+func (r *Router) Explain(req *http.Request) MatchTrace {
+	c := r.pool.Get().(*ctx)
+	c.parent = req.Context()
+	c.req = req
+	c.router = r
+
+	trace := c.startTrace(req)
+	h := r.root().hostrm.Match(c, req)
+	c.endTrace(h)
+
+	c.Reset()
+	r.pool.Put(c)
+	return *trace
+}
+
+// DebugHeader sets a response header containing the summary of the MatchTrace of each request
+// matching a route of this router or its subrouters. An empty name disables it, which is the default.
+// 	l.DebugHeader("X-Lion-Match") // X-Lion-Match: matched /users/:id (user) id=42
+// Requests that do not match a route use the setting of the router serving them.
+// Since tracing slows down the matching of every request once a router sets it, it should only be enabled while debugging.
+func (r *Router) DebugHeader(name string) {
+	r.debugHeader = &name
+	if name != "" {
+		r.root().traceRequests = true
+	}
+}
+
+func (r *Router) debugHeaderName() string {
+	if rr := r.nearest(func(rr *Router) bool { return rr.debugHeader != nil }); rr != nil {
+		return *rr.debugHeader
+	}
+	return ""
+}
+
+// startTrace starts recording the matching of req
+func (c *ctx) startTrace(req *http.Request) *MatchTrace {
+	c.trace = &MatchTrace{Method: req.Method, Host: req.Host, Path: req.URL.Path}
+	c.tracePhase = "path"
+	return c.trace
+}
+
+// endTrace completes the trace with the handler returned by the matching
+func (c *ctx) endTrace(h http.Handler) {
+	t := c.trace
+	if t.Result == "" {
+		if h == nil {
+			t.Result = MatchNotFound
+		} else {
+			t.Result = MatchFound
+		}
+	}
+	if len(c.params) > 0 {
+		t.Params = make(map[string]string, len(c.params))
+		for _, p := range c.params {
+			t.Params[p.key] = p.val
+		}
+	}
+}
+
+// traceStep records a decision of the router. It does nothing if the matching is not traced.
+func (c *ctx) traceStep(kind, value, detail string) {
+	if c.trace != nil {
+		c.trace.Steps = append(c.trace.Steps, MatchStep{Matcher: c.tracePhase, Kind: kind, Value: value, Detail: detail})
+	}
+}
+
+// traceResult records the result of the matching. It does nothing if the matching is not traced.
+func (c *ctx) traceResult(result string, rt *route, location string) {
+	if c.trace == nil {
+		return
+	}
+	c.trace.Result = result
+	c.trace.Location = location
+	if rt != nil {
+		c.trace.Pattern = rt.pattern
+		c.trace.Name = rt.name
+	}
+}
+
+// matcherContext returns the context passed to the matchers.
+// If the matching is traced, the context also records the steps of the matchers.
+func (c *ctx) matcherContext() matcher.Context {
+	if c.trace != nil {
+		return tracingContext{c}
+	}
+	return c
+}
+
+// tracingContext records the steps of the matchers in the trace of the context
+type tracingContext struct {
+	*ctx
+}
+
+func (tc tracingContext) Trace(s matcher.TraceStep) {
+	tc.trace.Steps = append(tc.trace.Steps, MatchStep{
+		Matcher: tc.tracePhase,
+		Kind:    s.Kind,
+		Node:    s.Node,
+		Search:  s.Search,
+		Param:   s.Param,
+		Value:   s.Value,
+	})
+}
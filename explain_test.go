@@ -0,0 +1,109 @@
+package lion
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	l := New()
+	l.Get("/users/:id", fakeHandler()).WithName("user")
+	l.Get("/users/:id/posts", fakeHandler())
+	l.Get("/repos/*path/blob/:ref", fakeHandler())
+	l.Get("/about", fakeHandler())
+
+	trace := l.Explain(httptest.NewRequest("GET", "/users/42", nil))
+	if trace.Result != MatchFound || trace.Pattern != "/users/:id" || trace.Name != "user" {
+		t.Errorf("unexpected result: %s", trace.Summary())
+	}
+	if trace.Params["id"] != "42" {
+		t.Errorf("expected the param id to be 42 but got %v", trace.Params)
+	}
+	if !hasStep(trace, "param", "id", "42") || !hasStep(trace, "match", "", "") {
+		t.Errorf("missing steps in trace:\n%s", trace)
+	}
+
+	trace = l.Explain(httptest.NewRequest("GET", "/repos/a/blob/b/blob/main", nil))
+	if trace.Result != MatchFound || trace.Params["path"] != "a/blob/b" || trace.Params["ref"] != "main" {
+		t.Errorf("unexpected result: %s", trace.Summary())
+	}
+	if !hasStep(trace, "remove", "path", "a") {
+		t.Errorf("the backtracking should be recorded:\n%s", trace)
+	}
+
+	trace = l.Explain(httptest.NewRequest("GET", "/about/", nil))
+	if trace.Result != MatchRedirect || trace.Location != "/about" || !hasStep(trace, "tsr", "", "") {
+		t.Errorf("unexpected result: %s\n%s", trace.Summary(), trace)
+	}
+
+	trace = l.Explain(httptest.NewRequest("POST", "/users/42", nil))
+	if trace.Result != MatchMethodNotAllowed || !hasStep(trace, "method", "", "POST") {
+		t.Errorf("unexpected result: %s\n%s", trace.Summary(), trace)
+	}
+
+	trace = l.Explain(httptest.NewRequest("GET", "/unknown", nil))
+	if trace.Result != MatchNotFound {
+		t.Errorf("unexpected result: %s", trace.Summary())
+	}
+
+	b, err := json.Marshal(l.Explain(httptest.NewRequest("GET", "/users/42", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"result":"matched"`) || !strings.Contains(string(b), `"kind":"param"`) {
+		t.Errorf("unexpected json: %s", b)
+	}
+}
+
+func TestDebugHeader(t *testing.T) {
+	l := New()
+	l.DebugHeader("X-Lion-Match")
+	l.Get("/users/:id", fakeHandler()).WithName("user")
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if h := w.Header().Get("X-Lion-Match"); h != "matched /users/:id (user) id=42" {
+		t.Errorf("unexpected debug header: %s", h)
+	}
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/posts", nil))
+	if h := w.Header().Get("X-Lion-Match"); h != MatchNotFound {
+		t.Errorf("unexpected debug header: %s", h)
+	}
+}
+
+func TestDebugHeaderSubrouter(t *testing.T) {
+	l := New()
+	l.Get("/health", fakeHandler())
+	api := l.Group("/api")
+	api.DebugHeader("X-Match")
+	api.Get("/users/:id", fakeHandler())
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/api/users/42", nil))
+	if h := w.Header().Get("X-Match"); h != "matched /api/users/:id id=42" {
+		t.Errorf("unexpected debug header: %s", h)
+	}
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if h := w.Header().Get("X-Match"); h != "" {
+		t.Errorf("the debug header should only be set for the routes of the group but got %s", h)
+	}
+}
+
+func hasStep(trace MatchTrace, kind, param, value string) bool {
+	for _, s := range trace.Steps {
+		if s.Kind == kind && (param == "" || s.Param == param) && (value == "" || s.Value == value) {
+			return true
+		}
+	}
+	return false
+}
@@ -62,7 +62,9 @@ func (hm *hostMatcher) Register(pattern string) registerMatcher {
 func (hm *hostMatcher) Match(c *ctx, req *http.Request) http.Handler {
 	if hm.multihost {
 		reversedHost := reverseHost(req.Host)
-		value, _ := hm.matcher.GetWithContext(c, reversedHost, nil)
+		c.tracePhase = "host"
+		value, _ := hm.matcher.GetWithContext(c.matcherContext(), reversedHost, nil)
+		c.tracePhase = "path"
 		// Delete wildcard param
 		// TODO: Skip this step for performance reasons
 		// (Maybe by adding a blacklisted or skiplisted params on host matcher)
@@ -74,6 +76,7 @@ func (hm *hostMatcher) Match(c *ctx, req *http.Request) http.Handler {
 			_, h := rm.Match(c, req)
 			return h
 		}
+		c.traceStep("host", req.Host, "no router for host")
 	} else {
 		_, h := hm.defaultRM.Match(c, req)
 		return h
@@ -183,3 +183,24 @@ func TestRaceCondition(t *testing.T) {
 		}()
 	}
 }
+
+func TestHostNotFoundHandler(t *testing.T) {
+	l := New()
+	l.Get("/", fakeHandler())
+
+	l.Host("admin.example.com")
+	l.Get("/", fakeHandler())
+	l.NotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("admin"))
+	}))
+
+	test := htest.New(t, l)
+	test.Get("http://admin.example.com/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectBody("admin")
+
+	test.Get("http://www.example.com/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectBody("404 page not found\n")
+}
@@ -0,0 +1,314 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+)
+
+// Check returns an error if registering pattern would panic: the pattern is invalid,
+// a param is defined with another name or type by a registered pattern
+// or an optional param would make it conflict with a registered route.
+// Set calls it before changing the tree so that a pattern is either fully registered or not at all.
+func (m *matcher) Check(pattern string) error {
+	expansions, err := m.tree.expansions(pattern)
+	if err != nil {
+		return err
+	}
+
+	var store Store
+	for i, splitted := range expansions {
+		n, err := m.tree.walkPattern(splitted, func(pn, sn *node) error {
+			switch {
+			case pn.pname != sn.pname:
+				return fmt.Errorf("Conflicting parameter name '%s' with '%s' for pattern: '%s'", pn.pname, sn.pname, pn.path())
+			case sn.nodeType == param && pn.typeName() != sn.typeName():
+				return fmt.Errorf("Conflicting parameter type for '%s' for pattern: '%s'", sn.pname, pn.path())
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case n == nil:
+		case i == 0:
+			store = n.store
+		case n.store != nil && n.store != store:
+			return fmt.Errorf("pattern %s conflicts with an already registered route at %s", pattern, n.path())
+		}
+	}
+	return nil
+}
+
+// CheckRegexp returns an error if a param of pattern is defined with another regular expression by a registered pattern.
+// It does not prevent the registration, the regular expression registered first is used.
+func (m *matcher) CheckRegexp(pattern string) error {
+	expansions, err := m.tree.expansions(pattern)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.tree.walkPattern(expansions[0], func(pn, sn *node) error {
+		if regexString(pn.re) != regexString(sn.re) {
+			return fmt.Errorf("the regular expression of parameter '%s' is replaced by the one of pattern: '%s'", sn.pname, pn.path())
+		}
+		return nil
+	})
+	return err
+}
+
+// walkPattern follows the nodes of a splitted pattern in the tree and returns the node registered for it, or nil.
+// fn is called with each param or wildcard node of the tree corresponding to a param or wildcard node of the pattern.
+func (tree *tree) walkPattern(splitted []*node, fn func(pn, sn *node) error) (*node, error) {
+	n := tree.root
+	for _, sn := range splitted {
+		switch sn.nodeType {
+		case param, wildcard:
+			pn := n.paramChild
+			if sn.nodeType == wildcard {
+				pn = n.anyChild
+			}
+			if pn == nil {
+				return nil, nil
+			}
+			if err := fn(pn, sn); err != nil {
+				return nil, err
+			}
+			n = pn
+		default:
+			search := sn.pattern
+			for search != "" {
+				c, ok := n.getStaticChild(search[0])
+				if !ok || c.longestPrefix(search) < len(c.pattern) {
+					// The rest of the pattern is not registered
+					return nil, nil
+				}
+				search = search[len(c.pattern):]
+				n = c
+			}
+		}
+	}
+	return n, nil
+}
+
+func regexString(re *regexp.Regexp) string {
+	if re == nil {
+		return ""
+	}
+	return re.String()
+}
+
+// Overlap returns a path matching both patterns a and b, if there is one.
+// It is derived from the structure of the patterns: static parts are matched against the params and wildcards of the
+// other pattern and values accepted by the params are used when both patterns have a param at the same place.
+// It does not tell which pattern the path matches first, the path should be looked up for that.
+func (m *matcher) Overlap(a, b string) (string, bool) {
+	ea, err := m.tree.expansions(a)
+	if err != nil {
+		return "", false
+	}
+	eb, err := m.tree.expansions(b)
+	if err != nil {
+		return "", false
+	}
+
+	for _, na := range ea {
+		for _, nb := range eb {
+			if path, ok := m.tree.unify(tokens(na), tokens(nb)); ok {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// token is a byte of a static part of a pattern or a param or wildcard node
+type token struct {
+	b byte
+	n *node
+}
+
+func tokens(splitted []*node) (out []token) {
+	for _, n := range splitted {
+		if n.nodeType != static {
+			out = append(out, token{n: n})
+			continue
+		}
+		for i := 0; i < len(n.pattern); i++ {
+			out = append(out, token{b: n.pattern[i]})
+		}
+	}
+	return
+}
+
+// rank orders tokens from the most specific to the most general
+func (t token) rank() int {
+	if t.n == nil {
+		return 0
+	}
+	if t.n.nodeType == param {
+		return 1
+	}
+	return 2
+}
+
+// unify returns a path matched by the tokens of a and b
+func (tree *tree) unify(a, b []token) (string, bool) {
+	if len(a) == 0 || len(b) == 0 {
+		return "", len(a) == len(b)
+	}
+
+	// x is the most general of the first tokens
+	x, y := a[0], b[0]
+	if y.rank() > x.rank() {
+		a, b, x, y = b, a, y, x
+	}
+
+	switch x.rank() {
+	case 0:
+		if x.b != y.b {
+			return "", false
+		}
+		rest, ok := tree.unify(a[1:], b[1:])
+		return string(x.b) + rest, ok
+	case 1:
+		if y.n != nil {
+			v, ok := tree.commonValue(x.n, y.n)
+			if !ok {
+				return "", false
+			}
+			rest, ok := tree.unify(a[1:], b[1:])
+			return v + rest, ok
+		}
+
+		// The value of the param is made of the static bytes of b in the same segment
+		for k := 1; k <= len(b) && b[k-1].n == nil && b[k-1].b != tree.MainSeparators()[0]; k++ {
+			v := literal(b[:k])
+			if !tree.accepts(x.n, v) {
+				continue
+			}
+			if rest, ok := tree.unify(a[1:], b[k:]); ok {
+				return v + rest, true
+			}
+		}
+	default:
+		// The value of the wildcard is made of one or more tokens of b
+		v := ""
+		for k := 1; k <= len(b); k++ {
+			tv, ok := tree.tokenValue(b[k-1])
+			if !ok {
+				break
+			}
+			v += tv
+			if rest, ok := tree.unify(a[1:], b[k:]); ok {
+				return v + rest, true
+			}
+		}
+	}
+	return "", false
+}
+
+func literal(tokens []token) string {
+	b := make([]byte, len(tokens))
+	for i, t := range tokens {
+		b[i] = t.b
+	}
+	return string(b)
+}
+
+// tokenValue returns the byte of a static token or a value accepted by a param or wildcard
+func (tree *tree) tokenValue(t token) (string, bool) {
+	if t.n == nil {
+		return string(t.b), true
+	}
+	return tree.commonValue(t.n, t.n)
+}
+
+// commonValue returns a value accepted by both param or wildcard nodes
+func (tree *tree) commonValue(n1, n2 *node) (string, bool) {
+	for _, n := range []*node{n1, n2} {
+		if v, ok := tree.sampleValue(n); ok && tree.accepts(n1, v) && tree.accepts(n2, v) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// sampleValue returns a value accepted by the param or wildcard node n.
+// It is derived from the regular expression or the sample of the type of n.
+func (tree *tree) sampleValue(n *node) (string, bool) {
+	switch {
+	case n.re != nil:
+		re, err := syntax.Parse(n.re.String(), syntax.Perl)
+		if err != nil {
+			return "", false
+		}
+		return sampleRegexp(re.Simplify())
+	case n.ptype != nil:
+		return n.ptype.Sample, n.ptype.Sample != ""
+	}
+	return "x", true
+}
+
+// accepts reports whether v is a valid value for the param or wildcard node n
+func (tree *tree) accepts(n *node, v string) bool {
+	if v == "" {
+		return false
+	}
+	if n.nodeType == wildcard {
+		return true
+	}
+	if stringsIndex(v, tree.MainSeparators()[0]) >= 0 {
+		return false
+	}
+	if n.re != nil && n.re.FindString(v) != v {
+		return false
+	}
+	return n.ptype == nil || n.ptype.Match(v)
+}
+
+// sampleRegexp returns the shortest string matched by re, picking the first choice of alternations and classes
+func sampleRegexp(re *syntax.Regexp) (string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary, syntax.OpStar, syntax.OpQuest:
+		return "", true
+	case syntax.OpLiteral:
+		return string(re.Rune), true
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return "", false
+		}
+		return string(re.Rune[0]), true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return "x", true
+	case syntax.OpCapture, syntax.OpPlus:
+		return sampleRegexp(re.Sub[0])
+	case syntax.OpRepeat:
+		s, ok := sampleRegexp(re.Sub[0])
+		out := ""
+		for i := 0; i < re.Min; i++ {
+			out += s
+		}
+		return out, ok
+	case syntax.OpConcat:
+		out := ""
+		for _, sub := range re.Sub {
+			s, ok := sampleRegexp(sub)
+			if !ok {
+				return "", false
+			}
+			out += s
+		}
+		return out, true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if s, ok := sampleRegexp(sub); ok {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
@@ -12,7 +12,6 @@ type Context interface {
 	AddParam(key, value string)
 	Remove(key string)
 	Reset()
-	SearchHistory() []string
 }
 
 type ctx struct {
@@ -34,10 +33,6 @@ func NewContextWithParent(c context.Context) Context {
 	}
 }
 
-func (c *ctx) SearchHistory() []string {
-	return []string{}
-}
-
 // Value returns the value for the passed key. If it is not found in the url params it returns parent's context Value
 func (p *ctx) Value(key interface{}) interface{} {
 	if k, ok := key.(string); ok {
@@ -3,6 +3,8 @@ package matcher
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 )
 
 var (
@@ -15,7 +17,12 @@ type Matcher interface {
 	Set(pattern string, values interface{}, tags Tags) Store
 	Get(pattern string, tags Tags) (Context, interface{}, error)
 	GetWithContext(c Context, pattern string, tags Tags) (interface{}, error)
-	Eval(pattern string, params map[string]string) (string, error)
+	LookupWithContext(c Context, pattern string, tags Tags) (Store, interface{}, error)
+	LookupFoldWithContext(c Context, pattern string, tags Tags) (Store, interface{}, error)
+	Eval(pattern string, params map[string]interface{}) (string, error)
+	Check(pattern string) error
+	CheckRegexp(pattern string) error
+	Overlap(a, b string) (string, bool)
 }
 
 type Store interface {
@@ -58,10 +65,27 @@ func Custom(cfg *Config) Matcher {
 	}
 }
 
+// Set registers values for the pattern.
+// Patterns with optional parameters are registered once for each possible expansion,
+// all the expansions share the same Store.
 func (m *matcher) Set(pattern string, values interface{}, tags Tags) Store {
-	value := m.tree.addRoute(m.tree.root, pattern, values, tags)
-	m.postvalidation(pattern)
-	return value
+	if err := m.Check(pattern); err != nil {
+		panicm("%s", err)
+	}
+
+	var store Store
+	for i, splitted := range m.tree.expand(pattern) {
+		n := m.tree.addRoute(m.tree.root, splitted)
+		if i == 0 {
+			store = m.tree.setValue(n, values, tags)
+			continue
+		}
+
+		if n.store == nil {
+			n.store = store
+		}
+	}
+	return store
 }
 
 func (m *matcher) Get(pattern string, tags Tags) (Context, interface{}, error) {
@@ -70,90 +94,117 @@ func (m *matcher) Get(pattern string, tags Tags) (Context, interface{}, error) {
 	return c, v, err
 }
 
+// GetWithContext returns the value stored for pattern and tags.
+// If a node matches the pattern but has no value for the tags, the node's Store is returned along with ErrTagsNotAllowed.
 func (m *matcher) GetWithContext(c Context, pattern string, tags Tags) (interface{}, error) {
-	n, err := m.tree.findNode(c, pattern, tags)
-	if err == ErrTSR {
-		return nil, ErrTSR
-	}
-	if n == nil {
-		return nil, ErrNotFound
-	}
-
-	val := m.tree.getValue(n, tags)
-	if val == nil {
-		return nil, ErrTagsNotAllowed
+	store, val, err := m.LookupWithContext(c, pattern, tags)
+	if err == ErrTagsNotAllowed {
+		return store, err
 	}
+	return val, err
+}
 
-	return val, nil
+// LookupWithContext is like GetWithContext but it also returns the Store of the matched node.
+func (m *matcher) LookupWithContext(c Context, pattern string, tags Tags) (Store, interface{}, error) {
+	return m.lookup(c, pattern, tags, false)
 }
 
-func (m *matcher) postvalidation(pattern string) {
-	// Find duplicate parameter names
-	m.findDuplicateParamNames(m.tree.root, pattern, []string{})
+// LookupFoldWithContext is like LookupWithContext but the static parts of the registered patterns are matched ignoring ASCII case.
+func (m *matcher) LookupFoldWithContext(c Context, pattern string, tags Tags) (Store, interface{}, error) {
+	return m.lookup(c, pattern, tags, true)
 }
 
-func (m *matcher) findDuplicateParamNames(n *node, pattern string, pnames []string) {
-	for _, sc := range n.staticChildren {
-		m.findDuplicateParamNames(sc, pattern, pnames)
+func (m *matcher) lookup(c Context, pattern string, tags Tags, fold bool) (Store, interface{}, error) {
+	n, err := m.tree.findNode(c, pattern, tags, fold)
+	if err == ErrTSR {
+		return nil, nil, ErrTSR
 	}
-
-	if n.paramChild != nil {
-		nn := n.paramChild
-		m.validateParamNode(nn, pattern, pnames)
-		m.findDuplicateParamNames(nn, pattern, append(pnames, nn.pname))
+	if n == nil {
+		return nil, nil, ErrNotFound
 	}
 
-	if n.anyChild != nil {
-		nn := n.anyChild
-		m.validateParamNode(nn, pattern, pnames)
-		m.findDuplicateParamNames(nn, pattern, append(pnames, nn.pname))
+	val := m.tree.getValue(n, tags)
+	if val == nil {
+		return n.store, nil, ErrTagsNotAllowed
 	}
-}
 
-func (m *matcher) validateParamNode(nn *node, pattern string, pnames []string) {
-	if nn.pname == "" {
-		panicm(`cannot use an unnamed parameter for  %s`, pattern)
-	}
-	if isInStringSlice(pnames, nn.pname) {
-		panicm("duplicate parameter %s for %s", nn.pname, pattern)
-	}
+	return n.store, val, nil
 }
 
-func (m *matcher) Eval(pattern string, params map[string]string) (string, error) {
+// Eval builds a path from a pattern and the values of its params.
+// Values that are not strings are converted using the Encode function of the param's type if any, or fmt.Sprint otherwise.
+// Values are percent-encoded, the values of wildcard params keep their main separators.
+func (m *matcher) Eval(pattern string, params map[string]interface{}) (string, error) {
 	// TODO: Avoid .split()
 	parents := m.tree.split(pattern)
 
 	var path string
-	for _, fn := range parents {
+	for i, fn := range parents {
 		switch fn.nodeType {
 		case static:
 			path += fn.pattern
 		case param:
-			p, ok := params[fn.pname]
+			v, ok := params[fn.pname]
+			if !ok && fn.optional {
+				return omitOptional(path, fn, parents[i+1:], params, m.tree.Separators())
+			}
 			if !ok {
 				return "", fmt.Errorf("Param '%s' not set", fn.pname)
 			}
 
-			if fn.re == nil {
-				path += p
-			} else {
+			p, err := fn.ptype.encode(v)
+			if err != nil {
+				return "", fmt.Errorf("Param '%s' cannot be encoded: %s", fn.pname, err)
+			}
+
+			if fn.re != nil {
 				if foundStr := fn.re.FindString(p); len(foundStr) != len(p) {
 					return "", fmt.Errorf("Param '%s' does not match entirely the regex pattern: '%s'", p, fn.re.String())
 				}
-				path += p
 			}
+
+			if fn.ptype != nil && !fn.ptype.Match(p) {
+				return "", fmt.Errorf("Param '%s' is not a valid %s", p, fn.ptype.Name)
+			}
+			path += url.PathEscape(p)
 		case wildcard:
-			p, ok := params[fn.pname]
+			v, ok := params[fn.pname]
+			if !ok && fn.optional {
+				return omitOptional(path, fn, parents[i+1:], params, m.tree.Separators())
+			}
 			if !ok {
 				return "", fmt.Errorf("Wildcard Param '%s' not set", fn.pname)
 			}
-			path += p
+			segments := strings.Split(fmt.Sprint(v), m.tree.MainSeparators())
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			path += strings.Join(segments, m.tree.MainSeparators())
 		}
 	}
 
 	return path, nil
 }
 
+// omitOptional returns the path built before the omitted optional parameter fn.
+// It returns an error if one of the following parameters is set since it cannot be part of the path without fn.
+func omitOptional(path string, fn *node, following []*node, params map[string]interface{}, separators string) (string, error) {
+	for _, n := range following {
+		if _, ok := params[n.pname]; ok && n.nodeType != static {
+			return "", fmt.Errorf("Param '%s' cannot be set without the optional param '%s'", n.pname, fn.pname)
+		}
+	}
+	return trimOptional(path, separators), nil
+}
+
+// trimOptional removes the separator preceding an omitted optional parameter
+func trimOptional(path, separators string) string {
+	if len(path) > 1 && isByteInString(path[len(path)-1], separators) {
+		return path[:len(path)-1]
+	}
+	return path
+}
+
 type Tags []string
 
 type noopParamTransformer struct{}
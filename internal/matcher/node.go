@@ -9,7 +9,7 @@ type nodeType uint8
 
 const (
 	static   nodeType = iota // /hello
-	param                    // /:id or /:id(regex)
+	param                    // /:id, /:id(regex) or /:id|type
 	wildcard                 // *
 )
 
@@ -17,11 +17,13 @@ type node struct {
 	nodeType    nodeType
 	pname       string
 	re          *regexp.Regexp
+	ptype       *ParamType
 	pattern     string
 	label       byte
 	endinglabel byte
 	store       Store
 	priority    int
+	optional    bool // only set on the nodes returned by tree.split
 
 	parent *node
 
@@ -34,6 +36,14 @@ func (n *node) longestPrefix(pattern string) int {
 	return longestPrefix(n.pattern, pattern)
 }
 
+// typeName returns the name of the param's type or an empty string if it is not typed
+func (n *node) typeName() string {
+	if n.ptype == nil {
+		return ""
+	}
+	return n.ptype.Name
+}
+
 func (n *node) children() nodes {
 	children := make([]*node, 0, len(n.staticChildren)+2)
 	for _, staticChild := range n.staticChildren {
@@ -121,3 +131,13 @@ func (n *node) calculatePriority() int {
 
 	return n.priority
 }
+
+// hasStaticChildFor reports whether n has a static child starting with label
+func (n *node) hasStaticChildFor(label byte, fold bool) bool {
+	for _, c := range n.staticChildren {
+		if c.label == label || fold && lowerASCII(c.label) == lowerASCII(label) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package matcher
+
+// Kinds of TraceStep
+const (
+	TraceStatic   = "static"   // a static node matched the beginning of the search
+	TraceParam    = "param"    // a param captured a value
+	TraceWildcard = "wildcard" // a wildcard captured a value
+	TraceRemove   = "remove"   // a param captured along a path that did not match was removed
+	TraceTSR      = "tsr"      // the search only differs from a node by a trailing slash
+	TraceMatch    = "match"    // the search matched a node
+)
+
+// TraceStep is a step of the search of a node matching a pattern
+type TraceStep struct {
+	Kind string
+	// Node is the path of the node in the tree
+	Node string
+	// Search is the part of the pattern that remained to be matched
+	Search string
+	Param  string
+	Value  string
+}
+
+// Tracer can be implemented by a Context to record the steps of a lookup
+type Tracer interface {
+	Trace(step TraceStep)
+}
@@ -7,9 +7,8 @@ import (
 )
 
 type tree struct {
-	root          *node
-	cfg           *Config
-	searchHistory []string
+	root *node
+	cfg  *Config
 
 	mainSep, optsSep string
 	allChars         string
@@ -86,153 +85,249 @@ func (t *tree) isLeaf(n *node, tags Tags) bool {
 	return t.getValue(n, tags) != nil
 }
 
-func (tree *tree) findNode(c Context, path string, tags Tags) (out *node, err error) {
-	n := tree.root
-	search := path
-	searchHistory := c.SearchHistory()
-	for {
+// maxAttempts bounds the number of values tried for params and wildcards while searching a path.
+// Patterns with several params or wildcards per segment can be matched in many ways,
+// the search stops with ErrNotFound once this number is reached.
+const maxAttempts = 1000
 
-		if search == "" && n.store != nil {
-			out = n
-			break
-		}
+// attempt is a param or wildcard node tried for the rest of a path of a given length
+type attempt struct {
+	n    *node
+	rest int
+}
+
+// match holds the state of the search of a path in the tree
+type match struct {
+	c    Context
+	tr   Tracer
+	fold bool
+
+	// failed records the attempts which did not lead to a match so that each one is tried once
+	failed   map[attempt]bool
+	attempts int
+}
+
+// findNode returns the node matching path.
+// If fold is true, static segments are compared to the path ignoring ASCII case, param values are kept as is.
+// If c implements Tracer, each step of the search is recorded.
+func (tree *tree) findNode(c Context, path string, tags Tags, fold bool) (out *node, err error) {
+	m := &match{c: c, fold: fold}
+	m.tr, _ = c.(Tracer)
+	return tree.matchNode(m, tree.root, path)
+}
 
-		var label byte
-		if search != "" {
-			label = search[0]
+// matchNode matches search against the children of n.
+// Static children are tried first, then the param child and finally the wildcard child.
+// If fold is true, every static child matching search ignoring case is tried, starting with the one matching its exact case.
+// A param or a wildcard can end at several positions of search, each one is tried in order
+// and the params added along the way are removed when it does not lead to a match.
+// For example, if we define:
+// 		/repos/*path/blob/:ref
+// and the user tries to fetch:
+// 		/repos/a/blob/b/blob/main
+// path is first set to a, which does not match since ref cannot contain a slash, then to a/blob/b which matches.
+func (tree *tree) matchNode(m *match, n *node, search string) (*node, error) {
+	c, tr, fold := m.c, m.tr, m.fold
+	if search == "" && n.store != nil {
+		if tr != nil {
+			tr.Trace(TraceStep{Kind: TraceMatch, Node: n.path()})
 		}
+		return n, nil
+	}
 
-		// We check if there is a present route starting with label byte
-		if nn, ok := n.getStaticChild(label); ok && stringsHasPrefix(search, nn.pattern) {
+	sep := tree.MainSeparators()
 
-			n = nn
+	// We check if there is a present route starting with the first byte of search
+	if search != "" {
+		nn, ok := n.getStaticChild(search[0])
+		exact := ok && stringsHasPrefix(search, nn.pattern)
+		tried := exact
+		if exact {
+			if out, err := tree.matchStatic(m, nn, search); out != nil || err != nil {
+				return out, err
+			}
+		}
 
-			searchHistory = append(searchHistory, search)
-			search = search[len(nn.pattern):]
-			if search == tree.MainSeparators() {
-				err = ErrTSR
-				break
+		// Each static child matching search ignoring case is tried in turn
+		if fold {
+			for _, fn := range n.staticChildren {
+				if (fn == nn && exact) || !stringsHasPrefixFold(search, fn.pattern) {
+					continue
+				}
+				tried = true
+				if out, err := tree.matchStatic(m, fn, search); out != nil || err != nil {
+					return out, err
+				}
 			}
-			continue
-		} else if ok && nn.endinglabel == tree.MainSeparators()[0] && len(search) < len(nn.pattern) && search == nn.pattern[:len(nn.pattern)-1] && nn.store != nil {
-			err = ErrTSR
-			break
 		}
 
-	PARAM:
-		// If there is a param child then we go for it.
-		if n.paramChild != nil {
-			pn := n.paramChild
-			p := -1
+		if !tried {
+			for _, sn := range n.staticChildren {
+				if (sn == nn || fold && lowerASCII(sn.label) == lowerASCII(search[0])) &&
+					sn.endinglabel == sep[0] && len(search) == len(sn.pattern)-1 && hasPrefix(search, sn.pattern[:len(search)], fold) && sn.store != nil {
+					if tr != nil {
+						tr.Trace(TraceStep{Kind: TraceTSR, Node: sn.path(), Search: search})
+					}
+					return nil, ErrTSR
+				}
+			}
+		}
+	}
 
+	// If there is a param child then we go for it.
+	if pn := n.paramChild; pn != nil {
+		for _, end := range tree.paramEnds(pn, search, fold) {
 			var pval string
-			if pn.re == nil { // normal parameter
-				var char byte
-				if pn.endinglabel > 0 {
-					char = pn.endinglabel
-				} else {
-					char = tree.MainSeparators()[0]
-				}
+			if pn.re == nil {
+				pval = tree.cfg.ParamTransformer.Transform(search[:end])
 
-				p = stringsIndex(search, char)
-				if p < 0 {
-					p = len(search)
+				// Typed parameter not matching, try the next end or the wildcard child
+				if pn.ptype != nil && !pn.ptype.Match(pval) {
+					continue
 				}
-
-				pval = tree.cfg.ParamTransformer.Transform(search[:p])
-			} else { // regex
+			} else {
 				pval = pn.re.FindString(tree.cfg.ParamTransformer.Transform(search))
-				p = len(pval)
+			}
+
+			rest := search[end:]
+			if !m.try(pn, rest) {
+				continue
+			}
+			if m.attempts > maxAttempts {
+				return nil, ErrNotFound
 			}
 
 			c.AddParam(pn.pname, pval)
-			searchHistory = append(searchHistory, search)
+			if tr != nil {
+				tr.Trace(TraceStep{Kind: TraceParam, Node: pn.path(), Search: search, Param: pn.pname, Value: pval})
+			}
 
-			n = n.paramChild
-			search = search[p:]
+			if rest == sep {
+				if tr != nil {
+					tr.Trace(TraceStep{Kind: TraceTSR, Node: pn.path(), Search: rest})
+				}
+				return nil, ErrTSR
+			}
+			if out, err := tree.matchNode(m, pn, rest); out != nil || err != nil {
+				return out, err
+			}
+			m.fail(pn, rest)
 
-			if search == tree.MainSeparators() {
-				err = ErrTSR
-				break
+			// Remove the parameter added for this attempt
+			c.Remove(pn.pname)
+			if tr != nil {
+				tr.Trace(TraceStep{Kind: TraceRemove, Node: pn.path(), Param: pn.pname, Value: pval})
 			}
-			continue
 		}
+	}
 
-	WILDCARD:
-		// If there is a wildcard child then we go for it.
-		if n.anyChild != nil {
-			n = n.anyChild
-
-			pval := tree.cfg.ParamTransformer.Transform(search)
-			c.AddParam(n.pname, pval)
+	// If there is a wildcard child then we go for it.
+	if wn := n.anyChild; wn != nil {
+		for _, end := range tree.wildcardEnds(wn, search, fold) {
+			rest := search[end:]
+			if !m.try(wn, rest) {
+				continue
+			}
+			if m.attempts > maxAttempts {
+				return nil, ErrNotFound
+			}
 
-			searchHistory = append(searchHistory, search)
-			search = search[len(search):]
-			continue
-		}
+			wval := tree.cfg.ParamTransformer.Transform(search[:end])
+			c.AddParam(wn.pname, wval)
+			if tr != nil {
+				tr.Trace(TraceStep{Kind: TraceWildcard, Node: wn.path(), Search: search, Param: wn.pname, Value: wval})
+			}
 
-		if search == "" && n.store != nil {
-			nn, ok := n.getStaticChild(tree.MainSeparators()[0])
-			if ok && nn.store != nil {
-				err = ErrTSR
-				break
+			if out, err := tree.matchNode(m, wn, rest); out != nil || err != nil {
+				return out, err
 			}
-		}
+			m.fail(wn, rest)
 
-		// Case where the current path starts with and is longer than the found node's (nn) static path
-		// Check the tests, for example if we define:
-		// 		/hello/contact/named
-		// 		/hello/contact/:param
-		// and the user tries to fetch:
-		// 		/hello/contact/nameddd
-		// it should go the second registered pattern (the one that has :param)
-		if search != "" && n.parent != nil && n.nodeType == static {
-			if n.parent.paramChild != nil && len(searchHistory) > 0 {
-				// Going to parent
-				n = n.parent
-
-				// Rollback search
-				search = searchHistory[len(searchHistory)-1]
-				searchHistory = searchHistory[:len(searchHistory)-1]
-				goto PARAM
+			c.Remove(wn.pname)
+			if tr != nil {
+				tr.Trace(TraceStep{Kind: TraceRemove, Node: wn.path(), Param: wn.pname, Value: wval})
 			}
 		}
+	}
 
-		// Finally if we were previously in a param node and there is no matched routes.
-		// We go back to the parent node and the previous search path.
-		// We then jump to the parent's wildcard node.
-		// If there was a previously registered param in the previous param node, we remove it.
-		if search != "" && n.parent != nil {
-			// Walk back up the tree to find if there is a wildcard node
-			for n.anyChild == nil && n.parent != nil && len(searchHistory) > 0 {
-				prevparam := n.pname
-
-				// Going to parent
-				n = n.parent
-
-				// Rollback search
-				search = searchHistory[len(searchHistory)-1]
-				searchHistory = searchHistory[:len(searchHistory)-1]
-
-				// Remove parameters added along the way
-				if prevparam != "" {
-					c.Remove(prevparam)
-				}
-				if n.anyChild != nil {
-					goto WILDCARD
-				}
-			}
+	return nil, nil
+}
+
+// try reports whether the param or wildcard node n should be tried for rest and counts the attempt
+func (m *match) try(n *node, rest string) bool {
+	if m.failed[attempt{n, len(rest)}] {
+		return false
+	}
+	m.attempts++
+	return true
+}
+
+// fail records that the param or wildcard node n does not lead to a match for rest
+func (m *match) fail(n *node, rest string) {
+	if m.failed == nil {
+		m.failed = make(map[attempt]bool)
+	}
+	m.failed[attempt{n, len(rest)}] = true
+}
+
+// matchStatic matches search against the static node nn whose pattern is a prefix of search
+func (tree *tree) matchStatic(m *match, nn *node, search string) (*node, error) {
+	if m.tr != nil {
+		m.tr.Trace(TraceStep{Kind: TraceStatic, Node: nn.path(), Search: search})
+	}
+	rest := search[len(nn.pattern):]
+	if rest == tree.MainSeparators() {
+		if m.tr != nil {
+			m.tr.Trace(TraceStep{Kind: TraceTSR, Node: nn.path(), Search: rest})
 		}
-		break
+		return nil, ErrTSR
+	}
+	return tree.matchNode(m, nn, rest)
+}
+
+// paramEnds returns the positions of search where the value of the param node pn can end.
+// The value of a param cannot contain a main separator, it ends before a static child of pn or at the end of the segment.
+func (tree *tree) paramEnds(pn *node, search string, fold bool) []int {
+	if pn.re != nil {
+		return []int{len(pn.re.FindString(tree.cfg.ParamTransformer.Transform(search)))}
 	}
 
-	return out, err
+	segment := stringsIndex(search, tree.MainSeparators()[0])
+	if segment < 0 {
+		segment = len(search)
+	}
+
+	var ends []int
+	for i := 0; i < segment; i++ {
+		if pn.hasStaticChildFor(search[i], fold) {
+			ends = append(ends, i)
+		}
+	}
+	return append(ends, segment)
 }
 
-func (tree *tree) addRoute(n *node, pattern string, values interface{}, tags Tags) Store {
-	splitted := tree.split(pattern)
-	pattern = strings.Replace(pattern, `\`, "", -1)
+// wildcardEnds returns the positions of search where the value of the wildcard node wn can end.
+// A wildcard consumes the rest of search unless it is followed by static children.
+func (tree *tree) wildcardEnds(wn *node, search string, fold bool) []int {
+	if len(wn.staticChildren) == 0 {
+		return []int{len(search)}
+	}
 
+	var ends []int
+	for i := 0; i < len(search); i++ {
+		if wn.hasStaticChildFor(search[i], fold) {
+			ends = append(ends, i)
+		}
+	}
+	if wn.store != nil {
+		ends = append(ends, len(search))
+	}
+	return ends
+}
+
+// addRoute inserts the splitted nodes of a pattern in the tree and returns the last node.
+// Conflicting parameter names and types are reported by Check before inserting.
+func (tree *tree) addRoute(n *node, splitted []*node) *node {
 	var cn *node
 	for _, cn = range splitted {
 	CONTINUE:
@@ -240,35 +335,17 @@ func (tree *tree) addRoute(n *node, pattern string, values interface{}, tags Tag
 		case cn.nodeType == param:
 			if n.paramChild == nil {
 				n.paramChild = cn
-			} else {
-				// Check conflicting parameter name
-				if n.paramChild.pname != cn.pname {
-					panicm("Conflicting parameter name '%s' with '%s' for pattern: '%s'",
-						n.paramChild.pname, cn.pname, n.paramChild.path())
-				}
 			}
 
 			cn.parent = n
 			n = n.paramChild
-
-			lcp := n.longestPrefix(pattern)
-			pattern = pattern[lcp:]
 		case cn.nodeType == wildcard:
 			if n.anyChild == nil {
 				n.anyChild = cn
-			} else {
-				// Check conflicting wildcard parameter name
-				if n.anyChild.pname != cn.pname {
-					panicm("Conflicting parameter name '%s' with '%s' for pattern: '%s'",
-						n.anyChild.pname, cn.pname, n.anyChild.path())
-				}
 			}
 
 			cn.parent = n
 			n = n.anyChild
-
-			lcp := n.longestPrefix(pattern)
-			pattern = pattern[lcp:]
 		default:
 			fn, ok := n.getStaticChild(cn.label)
 			if !ok {
@@ -277,22 +354,16 @@ func (tree *tree) addRoute(n *node, pattern string, values interface{}, tags Tag
 				n.setStaticChild(cn.label, cn)
 
 				cn.parent = n
-				lcp := cn.longestPrefix(pattern)
 				n = cn
-
-				pattern = pattern[lcp:]
-
 				continue
 			}
 
 			// Label already exist
-			lcp := fn.longestPrefix(pattern)
+			lcp := fn.longestPrefix(cn.pattern)
 			if lcp == len(fn.pattern) {
 				// If the longest common prefix (lcp) between the found node (fn) and the current pattern
 				// is equal to the found node's pattern.
 				// Then we can use the found node as the root node (n) and continue with the next splitted node. (with one exception, see below)
-				pattern = pattern[lcp:]
-
 				fn.parent = n
 				n = fn
 
@@ -331,7 +402,6 @@ func (tree *tree) addRoute(n *node, pattern string, values interface{}, tags Tag
 				n.setStaticChild(nfn.label, nfn)
 
 				n = nfn
-				pattern = pattern[lcp:]
 				continue
 			}
 
@@ -366,17 +436,102 @@ func (tree *tree) addRoute(n *node, pattern string, values interface{}, tags Tag
 			n.setStaticChild(nfn.label, nfn)
 
 			n = nfn
-			pattern = pattern[lcp:]
-
 			goto CONTINUE
 		}
 	}
 
-	return tree.setValue(n, values, tags)
+	return n
+}
+
+// expand splits a pattern into nodes.
+// If the pattern contains optional parameters, it returns one list of nodes for each optional parameter omitted.
+// For example, /posts/:year/:month?/:day? is expanded to /posts/:year/:month/:day, /posts/:year and /posts/:year/:month
+// It panics if the pattern is invalid.
+func (tree *tree) expand(pattern string) [][]*node {
+	out, err := tree.expansions(pattern)
+	if err != nil {
+		panicm("%s", err)
+	}
+	return out
 }
 
-// split splits a pattern into multiple nodes types
-func (tree *tree) split(pattern string) (out []*node) {
+// expansions is like expand but returns an error if the pattern is invalid
+func (tree *tree) expansions(pattern string) ([][]*node, error) {
+	splitted, err := tree.parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Params are identified by their names in the path
+	var pnames []string
+	for _, n := range splitted {
+		if n.nodeType == static {
+			continue
+		}
+		if n.pname == "" {
+			return nil, fmt.Errorf("cannot use an unnamed parameter for %s", pattern)
+		}
+		if isInStringSlice(pnames, n.pname) {
+			return nil, fmt.Errorf("duplicate parameter %s for %s", n.pname, pattern)
+		}
+		pnames = append(pnames, n.pname)
+	}
+
+	var cuts []int
+	for i, n := range splitted {
+		if n.optional {
+			cuts = append(cuts, i)
+		}
+	}
+
+	if len(cuts) == 0 {
+		return [][]*node{splitted}, nil
+	}
+
+	// Only separators and optional parameters are allowed after the first optional parameter
+	for _, n := range splitted[cuts[0]:] {
+		if !n.optional && (n.nodeType != static || len(n.pattern) != 1 || !isByteInString(n.pattern[0], tree.Separators())) {
+			return nil, fmt.Errorf("optional parameters must be at the end of the pattern and separated by a single separator in %s", pattern)
+		}
+	}
+
+	out := [][]*node{splitted}
+	for _, cut := range cuts {
+		if cut == 0 {
+			continue
+		}
+
+		// Each expansion uses its own nodes since they are inserted in the tree
+		nodes := tree.split(pattern)[:cut]
+
+		// Remove the separator preceding the omitted parameter
+		last := nodes[len(nodes)-1]
+		if last.nodeType == static && isByteInString(last.pattern[len(last.pattern)-1], tree.Separators()) {
+			if len(last.pattern) > 1 {
+				last.pattern = last.pattern[:len(last.pattern)-1]
+				last.endinglabel = last.pattern[len(last.pattern)-1]
+			} else if len(nodes) > 1 {
+				nodes = nodes[:len(nodes)-1]
+			}
+		}
+
+		out = append(out, nodes)
+	}
+
+	return out, nil
+}
+
+// split splits a pattern into multiple nodes types. It panics if the pattern is invalid.
+func (tree *tree) split(pattern string) []*node {
+	out, err := tree.parse(pattern)
+	if err != nil {
+		panicm("%s", err)
+	}
+	return out
+}
+
+// parse splits a pattern into multiple nodes types or returns an error if the pattern is invalid
+func (tree *tree) parse(pattern string) (out []*node, err error) {
 	base := pattern
 	for {
 		if pattern == "" {
@@ -398,52 +553,80 @@ func (tree *tree) split(pattern string) (out []*node) {
 		switch c {
 		case tree.ParamChar():
 			var l byte
-			idx := strings.Index(base, pattern[:end])
-			if idx > 0 {
+			if idx := len(base) - len(pattern); idx > 0 {
 				l = base[idx-1]
 			}
 
-			endinglabel = 0
-			if len(pattern) > end {
-				endinglabel = pattern[end]
+			var pname string
+			pname, end, err = tree.paramName(base, pattern)
+			if err != nil {
+				return nil, err
 			}
-
 			child = &node{
-				pattern:     pattern[:end],
-				nodeType:    param,
-				pname:       pattern[1:end],
-				endinglabel: endinglabel,
-				label:       l,
+				pattern:  pattern[:end],
+				nodeType: param,
+				pname:    pname,
+				label:    l,
 			}
 
-			// Check if this param contains a regex definition
-			parenthesisIdx := strings.Index(pattern[:end], "(")
-			if parenthesisIdx > 0 { // Regex param
-				startp, endp := nextParenthesis(pattern)
-				child.re = regexp.MustCompile(pattern[startp+1 : endp])
+			switch {
+			case end < len(pattern) && pattern[end] == '(': // Regex param
+				startp, endp, err := nextParenthesis(pattern)
+				if err != nil {
+					return nil, err
+				}
+				if child.re, err = regexp.Compile(pattern[startp+1 : endp]); err != nil {
+					return nil, fmt.Errorf("invalid regular expression for parameter '%s' in %s: %s", pname, base, err)
+				}
 
-				// Update child
 				end = endp + 1
-				child.pname = pattern[1:startp]
 				child.pattern = pattern[:end]
-				child.endinglabel = pattern[end-1]
+			case end < len(pattern) && pattern[end] == '|': // Typed param
+				tend := end + 1 + identLen(pattern[end+1:])
+				tname := pattern[end+1 : tend]
+				pt, ok := lookupParamType(tname)
+				if !ok {
+					return nil, fmt.Errorf("unknown parameter type '%s' in %s", tname, base)
+				}
+				child.ptype = pt
+
+				end = tend
+				child.pattern = pattern[:end]
+			}
+
+			// Optional param
+			if end < len(pattern) && pattern[end] == '?' {
+				child.optional = true
+				end++
+			}
+
+			if end < len(pattern) {
+				child.endinglabel = pattern[end]
 			}
 
 			out = append(out, child)
 		case tree.WildcardChar():
-			pname := pattern[1:]
+			// A wildcard can be followed by other nodes such as /repos/*path/blob/:ref
+			var pname string
+			pname, end, err = tree.paramName(base, pattern)
+			if err != nil {
+				return nil, err
+			}
 			if pname == "" {
 				pname = "*"
 			}
 			child = &node{
-				pattern:  pattern,
+				pattern:  pattern[:end],
 				nodeType: wildcard,
 				pname:    pname,
 			}
 
+			if end < len(pattern) && pattern[end] == '?' {
+				child.optional = true
+				end++
+			}
+
 			out = append(out, child)
-			pattern = ""
-			continue
 		default:
 			charIdx := stringsIndexAnyNotEscaped(pattern, tree.AllChars())
 			if charIdx < 0 {
@@ -476,6 +659,30 @@ func (tree *tree) split(pattern string) (out []*node) {
 	return
 }
 
+// paramName returns the name of the param or wildcard at the beginning of pattern and the length of pattern it spans.
+// The name ends at the first character which is not a letter, a digit or an underscore, such as w in /img/:w-x-:h.png.
+// Names containing other characters must be enclosed in braces, for example /users/:{user-id}.
+func (tree *tree) paramName(base, pattern string) (name string, end int, err error) {
+	if len(pattern) > 1 && pattern[1] == '{' {
+		end = stringsIndex(pattern, '}')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unclosed brace in %s", base)
+		}
+		name, end = pattern[2:end], end+1
+		if strings.ContainsAny(name, tree.AllChars()+"{") {
+			return "", 0, fmt.Errorf("invalid parameter name '%s' in %s", name, base)
+		}
+	} else {
+		end = 1 + identLen(pattern[1:])
+		name = pattern[1:end]
+	}
+
+	if end < len(pattern) && isByteInString(pattern[end], tree.AllChars()) {
+		return "", 0, fmt.Errorf("parameter '%s' must be followed by a literal or a separator in %s", name, base)
+	}
+	return name, end, nil
+}
+
 func (tree *tree) printTree(n *node, decalage int) (out string) {
 	dec := strings.Repeat("\t", decalage)
 
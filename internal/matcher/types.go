@@ -0,0 +1,76 @@
+package matcher
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ParamType defines a named constraint that can be used in patterns: /users/:id|int
+type ParamType struct {
+	Name string
+
+	// Match reports whether the value extracted from the path is valid
+	Match func(value string) bool
+
+	// Encode converts a value to its path representation when building paths. It is optional.
+	Encode func(value interface{}) (string, error)
+
+	// Sample is a value accepted by Match used to build example paths. It is optional.
+	Sample string
+}
+
+var (
+	paramTypes   = map[string]*ParamType{}
+	paramTypesMu sync.RWMutex
+)
+
+// RegisterParamType registers a ParamType. It replaces any ParamType previously registered with the same name.
+func RegisterParamType(pt ParamType) {
+	if pt.Name == "" {
+		panicm("parameter type name should not be empty")
+	}
+	if pt.Match == nil {
+		panicm("parameter type %s should have a Match function", pt.Name)
+	}
+
+	paramTypesMu.Lock()
+	paramTypes[pt.Name] = &pt
+	paramTypesMu.Unlock()
+}
+
+// SaveParamTypes returns a function restoring the registered parameter types as they are when it is called.
+// It is used by tests registering temporary types.
+func SaveParamTypes() (restore func()) {
+	paramTypesMu.RLock()
+	saved := make(map[string]*ParamType, len(paramTypes))
+	for name, pt := range paramTypes {
+		saved[name] = pt
+	}
+	paramTypesMu.RUnlock()
+
+	return func() {
+		paramTypesMu.Lock()
+		paramTypes = saved
+		paramTypesMu.Unlock()
+	}
+}
+
+func lookupParamType(name string) (*ParamType, bool) {
+	paramTypesMu.RLock()
+	pt, ok := paramTypes[name]
+	paramTypesMu.RUnlock()
+	return pt, ok
+}
+
+// encode converts the value of a param to a string
+func (pt *ParamType) encode(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	if pt != nil && pt.Encode != nil {
+		return pt.Encode(value)
+	}
+
+	return fmt.Sprint(value), nil
+}
@@ -84,6 +84,46 @@ func stringsHasPrefix(str, prefix string) bool {
 	return false
 }
 
+// stringsHasPrefixFold is like stringsHasPrefix but ignores ASCII case
+func stringsHasPrefixFold(str, prefix string) bool {
+	if len(str) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if lowerASCII(str[i]) != lowerASCII(prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPrefix(str, prefix string, fold bool) bool {
+	if fold {
+		return stringsHasPrefixFold(str, prefix)
+	}
+	return stringsHasPrefix(str, prefix)
+}
+
+func lowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// identLen returns the length of the identifier at the beginning of s, such as the name of a param type.
+// Identifiers are made of ASCII letters, digits and underscores.
+func identLen(s string) int {
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			break
+		}
+	}
+	return i
+}
+
 func panicm(format string, args ...interface{}) {
 	panic(fmt.Sprintf("lion: "+format, args...))
 }
@@ -116,7 +156,7 @@ func isInStringSlice(slice []string, expected string) bool {
 
 // nextParenthesis finds the starting and ending indices of the openning and closing parenthesis characters: '(' and ')'
 // inspired by https://github.com/gorilla/mux/blob/master/regexp.go#L214
-func nextParenthesis(pattern string) (start, end int) {
+func nextParenthesis(pattern string) (start, end int, err error) {
 	level := 0
 	for i := 0; i < len(pattern); i++ {
 		c := pattern[i]
@@ -132,13 +172,13 @@ func nextParenthesis(pattern string) (start, end int) {
 				end = i
 				return
 			} else if level < 0 {
-				panicm("too many closed parenthesis in %s", pattern)
+				return 0, 0, fmt.Errorf("too many closed parenthesis in %s", pattern)
 			}
 		}
 	}
 
 	if level != 0 {
-		panicm("unbalanced parenthesis in %s", pattern)
+		return 0, 0, fmt.Errorf("unbalanced parenthesis in %s", pattern)
 	}
 
 	return
@@ -0,0 +1,305 @@
+package lion
+
+import (
+	"fmt"
+
+	"github.com/celrenheit/lion/internal/matcher"
+)
+
+// Kinds of RouteIssue
+const (
+	IssueDuplicateName = "duplicate name"
+	IssueConflict      = "conflict"
+	IssueUnreachable   = "unreachable"
+	IssueShadowed      = "shadowed"
+	IssueInvalid       = "invalid"
+)
+
+// RouteIssue is an issue found by Router.Validate or Router.Check
+type RouteIssue struct {
+	// Kind is one of IssueDuplicateName, IssueConflict, IssueUnreachable, IssueShadowed or IssueInvalid
+	Kind    string
+	Host    string
+	Pattern string
+	// Other is the pattern of the other route involved in the issue, or its host for the issues between hosts
+	Other string
+	// Example is a path, or a host for the issues between hosts, exhibiting the issue
+	Example string
+	Message string
+}
+
+// String returns the kind and the message of the issue
+func (i RouteIssue) String() string {
+	return i.Kind + ": " + i.Message
+}
+
+// Validate checks the routes registered on this router and its subrouters and returns the issues found:
+// 	- IssueDuplicateName: several routes have the same name and Routes.ByName only returns the first one
+// 	- IssueConflict: a param is defined with another regular expression by a pattern registered before, which is used instead
+// 	- IssueUnreachable: no request can match the route, for example a route without handler or /users/ when /users is registered
+// 	  since requests to /users/ are redirected
+// 	- IssueShadowed: requests matching the route, or its host, are matched by another route or host.
+// 	  It is usually intended when a static route such as /users/new takes precedence over /users/:id
+//
+// The examples are derived from the patterns: the static parts of a pattern are used as values for the params
+// of the other one. When both have a param at the same place, a value accepted by their regular expressions or types is used.
+// Overlaps are not reported for params of a type registered with RegisterParamType since no such value is known.
+//
+// Registrations that would panic, such as conflicting param names or types, cannot be found since they are not registered.
+// Use Router.Check before registering a route to find them.
+//
+// It can be called from a test to fail before serving:
+// 	for _, issue := range l.Validate() {
+// 		if issue.Kind != lion.IssueShadowed {
+// 			t.Error(issue)
+// 		}
+// 	}
+func (r *Router) Validate() []RouteIssue {
+	var (
+		routes   []*route
+		seen     = map[*route]bool{}
+		matchers []registerMatcher
+		byRM     = map[registerMatcher][]*route{}
+	)
+	for _, rt := range r.Routes() {
+		rt := rt.(*route)
+		if seen[rt] {
+			continue
+		}
+		seen[rt] = true
+		routes = append(routes, rt)
+
+		if _, ok := byRM[rt.pathMatcher]; !ok {
+			matchers = append(matchers, rt.pathMatcher)
+		}
+		byRM[rt.pathMatcher] = append(byRM[rt.pathMatcher], rt)
+	}
+
+	issues := duplicateNames(routes)
+	issues = append(issues, r.root().hostrm.validate(routes)...)
+	for _, rm := range matchers {
+		if pm, ok := rm.(*pathMatcher); ok {
+			issues = append(issues, pm.validate(byRM[rm])...)
+		}
+	}
+
+	// The same overlap can be found from both routes
+	unique := issues[:0]
+	found := map[RouteIssue]bool{}
+	for _, issue := range issues {
+		if !found[issue] {
+			found[issue] = true
+			unique = append(unique, issue)
+		}
+	}
+	return unique
+}
+
+func duplicateNames(routes []*route) (issues []RouteIssue) {
+	first := map[string]*route{}
+	for _, rt := range routes {
+		if rt.name == "" {
+			continue
+		}
+		if prev, ok := first[rt.name]; ok {
+			issues = append(issues, RouteIssue{
+				Kind:    IssueDuplicateName,
+				Host:    rt.host,
+				Pattern: rt.pattern,
+				Other:   prev.pattern,
+				Message: fmt.Sprintf("the name %s of %s is already used by %s", rt.name, rt.pattern, prev.pattern),
+			})
+			continue
+		}
+		first[rt.name] = rt
+	}
+	return
+}
+
+// Check returns the issues of registering a route for method and pattern on this router, without registering it:
+// 	- IssueInvalid: the registration would panic, for example if the method is not registered, the pattern is invalid
+// 	  or one of its params is defined with another name or type by a registered pattern
+// 	- IssueConflict: a param is defined with another regular expression by a registered pattern, which is used instead
+//
+// It can be called before registering routes coming from a configuration:
+// 	if issues := l.Check(cfg.Method, cfg.Pattern); len(issues) > 0 {
+// 		log.Printf("%s %s: %v", cfg.Method, cfg.Pattern, issues)
+// 	}
+func (r *Router) Check(method, pattern string) (issues []RouteIssue) {
+	p := r.routePattern(pattern)
+	invalid := func(err string) {
+		issues = append(issues, RouteIssue{Kind: IssueInvalid, Host: r.host, Pattern: p, Message: err})
+	}
+
+	if len(p) == 0 || p[0] != '/' {
+		invalid("path must begin with '/' in path '" + p + "'")
+		return
+	}
+	if !isRegisteredMethod(method) {
+		invalid(fmt.Sprintf("invalid http method %s, should be one of %v or registered using RegisterMethod", method, httpMethods()))
+	}
+
+	m := r.pathMatcherFor(r.host).matcher
+	if err := m.Check(p); err != nil {
+		invalid(err.Error())
+	} else if err := m.CheckRegexp(p); err != nil {
+		issues = append(issues, RouteIssue{Kind: IssueConflict, Host: r.host, Pattern: p, Message: err.Error()})
+	}
+	return
+}
+
+// pathMatcherFor returns the matcher of the routes registered for host without registering it.
+// It returns an empty matcher if there is none.
+func (r *Router) pathMatcherFor(host string) *pathMatcher {
+	hm := r.root().hostrm
+	if host == "" {
+		return hm.defaultRM.(*pathMatcher)
+	}
+	for _, rt := range r.root().Routes() {
+		if rt.Host() == host {
+			return rt.(*route).pathMatcher.(*pathMatcher)
+		}
+	}
+	return newPathMatcher()
+}
+
+// validate returns the issues between the routes of this matcher
+func (d *pathMatcher) validate(routes []*route) (issues []RouteIssue) {
+	// Each route is also registered alone to find out which routes would match an example path
+	alone := make([]matcher.Matcher, len(routes))
+	for i, rt := range routes {
+		if err := d.matcher.CheckRegexp(rt.pattern); err != nil {
+			issues = append(issues, RouteIssue{Kind: IssueConflict, Host: rt.host, Pattern: rt.pattern, Message: err.Error()})
+		}
+		alone[i] = newPathMatcher().matcher
+		alone[i].Set(rt.pattern, nil, matcher.Tags{GET})
+	}
+
+	for _, rt := range routes {
+		if len(rt.Methods()) == 0 {
+			issues = append(issues, RouteIssue{
+				Kind:    IssueUnreachable,
+				Host:    rt.host,
+				Pattern: rt.pattern,
+				Message: fmt.Sprintf("%s has no handler", rt.pattern),
+			})
+			continue
+		}
+
+		// A path built from the pattern alone should match the route
+		if example, ok := d.matcher.Overlap(rt.pattern, rt.pattern); ok {
+			if found := d.lookup(example); found != rt {
+				issue := RouteIssue{Kind: IssueUnreachable, Host: rt.host, Pattern: rt.pattern, Example: example}
+				if found == nil {
+					issue.Message = fmt.Sprintf("requests to %s do not match %s", example, rt.pattern)
+				} else {
+					issue.Kind = IssueShadowed
+					issue.Other = found.pattern
+					issue.Message = fmt.Sprintf("requests to %s match %s instead of %s", example, found.pattern, rt.pattern)
+				}
+				issues = append(issues, issue)
+				continue
+			}
+		}
+
+		for i, other := range routes {
+			if other == rt {
+				continue
+			}
+			example, ok := d.matcher.Overlap(rt.pattern, other.pattern)
+			if !ok || d.lookup(example) != rt {
+				continue
+			}
+			if _, _, err := alone[i].LookupWithContext(matcher.NewContext(), example, nil); err != matcher.ErrTagsNotAllowed {
+				continue
+			}
+			issues = append(issues, RouteIssue{
+				Kind:    IssueShadowed,
+				Host:    other.host,
+				Pattern: other.pattern,
+				Other:   rt.pattern,
+				Example: example,
+				Message: fmt.Sprintf("requests to %s match %s instead of %s", example, rt.pattern, other.pattern),
+			})
+		}
+	}
+	return
+}
+
+// lookup returns the route matching path
+func (d *pathMatcher) lookup(path string) *route {
+	// The lookup is done without tags so that the store is returned with ErrTagsNotAllowed
+	store, _, _ := d.matcher.LookupWithContext(matcher.NewContext(), path, nil)
+	rt, _ := store.(*route)
+	return rt
+}
+
+// validate returns the issues between the hosts of routes
+func (hm *hostMatcher) validate(routes []*route) (issues []RouteIssue) {
+	if !hm.multihost {
+		return nil
+	}
+
+	var hosts []string
+	byHost := map[string]registerMatcher{}
+	byRM := map[interface{}]string{}
+	for _, rt := range routes {
+		if _, ok := byHost[rt.host]; !ok && rt.host != "" {
+			hosts = append(hosts, rt.host)
+		}
+		byHost[rt.host] = rt.pathMatcher
+		byRM[rt.pathMatcher] = rt.host
+	}
+
+	alone := make([]matcher.Matcher, len(hosts))
+	for i, host := range hosts {
+		alone[i] = newHostMatcher().matcher
+		alone[i].Set(reverseHost(host), &hostStore{}, nil)
+	}
+
+	lookup := func(reversed string) interface{} {
+		v, _ := hm.matcher.GetWithContext(matcher.NewContext(), reversed, nil)
+		return v
+	}
+
+	for _, host := range hosts {
+		// A host built from the pattern alone should match it
+		if reversed, ok := hm.matcher.Overlap(reverseHost(host), reverseHost(host)); ok {
+			if found := lookup(reversed); found != byHost[host] {
+				example := reverseHost(reversed)
+				issue := RouteIssue{Kind: IssueUnreachable, Host: host, Example: example}
+				if other, ok := byRM[found]; ok && other != "" {
+					issue.Kind = IssueShadowed
+					issue.Other = other
+					issue.Message = fmt.Sprintf("requests to the host %s match %s instead of %s", example, other, host)
+				} else {
+					issue.Message = fmt.Sprintf("requests to the host %s do not match %s", example, host)
+				}
+				issues = append(issues, issue)
+				continue
+			}
+		}
+
+		for i, other := range hosts {
+			if other == host {
+				continue
+			}
+			reversed, ok := hm.matcher.Overlap(reverseHost(host), reverseHost(other))
+			if !ok || lookup(reversed) != byHost[host] {
+				continue
+			}
+			if v, err := alone[i].GetWithContext(matcher.NewContext(), reversed, nil); err != nil || v == nil {
+				continue
+			}
+			example := reverseHost(reversed)
+			issues = append(issues, RouteIssue{
+				Kind:    IssueShadowed,
+				Host:    other,
+				Other:   host,
+				Example: example,
+				Message: fmt.Sprintf("requests to the host %s match %s instead of %s", example, host, other),
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,152 @@
+package lion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/celrenheit/htest"
+)
+
+func TestRouterValidate(t *testing.T) {
+	l := New()
+	l.Get("/users/:id", fakeHandler()).WithName("user")
+	l.Get("/users/new", fakeHandler()).WithName("user")
+	l.Get("/about", fakeHandler())
+	l.Get("/about/", fakeHandler())
+	l.Get("/empty", nil)
+	l.Get("/n/:id([0-9]+)", fakeHandler())
+	l.Get("/n/:id([a-z]+)/x", fakeHandler())
+	l.Get("/posts/:id", fakeHandler())
+
+	issues := l.Validate()
+	expected := []RouteIssue{
+		{Kind: IssueDuplicateName, Pattern: "/users/new", Other: "/users/:id"},
+		{Kind: IssueShadowed, Pattern: "/users/:id", Other: "/users/new", Example: "/users/new"},
+		{Kind: IssueUnreachable, Pattern: "/about/", Example: "/about/"},
+		{Kind: IssueUnreachable, Pattern: "/empty"},
+		{Kind: IssueConflict, Pattern: "/n/:id([a-z]+)/x"},
+	}
+	for _, e := range expected {
+		if !hasIssue(issues, e) {
+			t.Errorf("expected issue %+v in %v", e, issues)
+		}
+	}
+	for _, i := range issues {
+		if i.Pattern == "/posts/:id" || i.Pattern == "/about" {
+			t.Errorf("unexpected issue for %s: %v", i.Pattern, i)
+		}
+	}
+
+	l = New()
+	l.Get("/files/:name", fakeHandler())
+	l.Get("/files/*path", fakeHandler())
+	if issues := l.Validate(); !hasIssue(issues, RouteIssue{Kind: IssueShadowed, Pattern: "/files/*path", Other: "/files/:name", Example: "/files/x"}) {
+		t.Errorf("the wildcard should be shadowed by the param: %v", issues)
+	}
+
+	l = New()
+	l.Get("/users/:id", fakeHandler())
+	l.Get("/posts/:id|int", fakeHandler())
+	if issues := l.Validate(); len(issues) != 0 {
+		t.Errorf("expected no issues but got %v", issues)
+	}
+}
+
+func TestRouterValidateTypes(t *testing.T) {
+	l := New()
+	l.Get("/items/:id|int", fakeHandler())
+	l.Get("/items/new", fakeHandler())
+	l.Get("/items/42", fakeHandler())
+	l.Get("/codes/:code([A-Z]{3})", fakeHandler())
+	l.Get("/codes/:code([A-Z]{3})/*rest", fakeHandler())
+
+	issues := l.Validate()
+	if !hasIssue(issues, RouteIssue{Kind: IssueShadowed, Pattern: "/items/:id|int", Other: "/items/42", Example: "/items/42"}) {
+		t.Errorf("the typed param should be shadowed by the static route accepted by its type: %v", issues)
+	}
+	for _, i := range issues {
+		if i.Other == "/items/new" || i.Pattern == "/items/new" || i.Pattern == "/items/42" {
+			t.Errorf("unexpected issue %v", i)
+		}
+		if i.Kind == IssueUnreachable {
+			t.Errorf("the regular expressions should be used to build examples: %v", i)
+		}
+	}
+}
+
+func TestRouterCheck(t *testing.T) {
+	l := New()
+	l.Get("/users/:id", fakeHandler())
+	l.Get("/n/:id([0-9]+)", fakeHandler())
+	api := l.Group("/api")
+
+	tests := []struct {
+		router          *Router
+		method, pattern string
+		expected        RouteIssue
+		message         string
+	}{
+		{l, GET, "users", RouteIssue{Kind: IssueInvalid, Pattern: "users"}, "path must begin with '/'"},
+		{l, "FETCH", "/fetch", RouteIssue{Kind: IssueInvalid, Pattern: "/fetch"}, "invalid http method"},
+		{l, GET, "/users/:name", RouteIssue{Kind: IssueInvalid, Pattern: "/users/:name"}, "Conflicting parameter name"},
+		{l, GET, "/a/:id/b/:id", RouteIssue{Kind: IssueInvalid, Pattern: "/a/:id/b/:id"}, "duplicate parameter id"},
+		{l, GET, "/posts/:id|unknown", RouteIssue{Kind: IssueInvalid, Pattern: "/posts/:id|unknown"}, "unknown parameter type"},
+		{l, GET, "/re/:id([0-9]+", RouteIssue{Kind: IssueInvalid, Pattern: "/re/:id([0-9]+"}, "unbalanced parenthesis"},
+		{l, GET, "/n/:id([a-z]+)", RouteIssue{Kind: IssueConflict, Pattern: "/n/:id([a-z]+)"}, "regular expression"},
+		{api, GET, "/:id/:id", RouteIssue{Kind: IssueInvalid, Pattern: "/api/:id/:id"}, "duplicate parameter id"},
+	}
+	for _, test := range tests {
+		issues := test.router.Check(test.method, test.pattern)
+		if len(issues) != 1 || !hasIssue(issues, test.expected) || !strings.Contains(issues[0].Message, test.message) {
+			t.Errorf("%s %s: expected an issue %+v containing %q but got %v", test.method, test.pattern, test.expected, test.message, issues)
+		}
+	}
+
+	if issues := api.Check(GET, "/users/:id"); len(issues) != 0 {
+		t.Errorf("unexpected issues %v", issues)
+	}
+	if len(l.Routes()) != 2 {
+		t.Errorf("Check should not register routes: %v", l.Routes())
+	}
+
+	// A literal param character is not mistaken for the param registered after it
+	esc := New()
+	esc.Get(`/\:`, fakeHandlerWithBody("colon"))
+	if issues := esc.Check(GET, "/:id"); len(issues) != 0 {
+		t.Errorf("unexpected issues %v", issues)
+	}
+	if recv := catchPanic(func() { esc.Get("/:id", fakeHandlerWithBody("id")) }); recv != nil {
+		t.Fatalf("a pattern accepted by Check should not panic: %v", recv)
+	}
+	test := htest.New(t, esc)
+	test.Get("/:").Do().ExpectBody("colon")
+	test.Get("/42").Do().ExpectBody("id")
+}
+
+func TestRouterValidateHosts(t *testing.T) {
+	l := New()
+	l.Host("$sub.example.com").Get("/", fakeHandler())
+	l.Host("*.example.com").Get("/", fakeHandler())
+	l.Host("api.example.com").Get("/", fakeHandler())
+
+	issues := l.Validate()
+	if !hasIssue(issues, RouteIssue{Kind: IssueShadowed, Host: "*.example.com", Other: "$sub.example.com", Example: "x.example.com"}) {
+		t.Errorf("the wildcard host should be shadowed by the param host: %v", issues)
+	}
+	if !hasIssue(issues, RouteIssue{Kind: IssueShadowed, Host: "$sub.example.com", Other: "api.example.com", Example: "api.example.com"}) {
+		t.Errorf("the param host should be shadowed by the static host: %v", issues)
+	}
+}
+
+// hasIssue reports whether issues contains an issue with the fields set in expected
+func hasIssue(issues []RouteIssue, expected RouteIssue) bool {
+	for _, i := range issues {
+		if i.Kind == expected.Kind && i.Pattern == expected.Pattern &&
+			(expected.Host == "" || i.Host == expected.Host) &&
+			(expected.Other == "" || i.Other == expected.Other) &&
+			(expected.Example == "" || i.Example == expected.Example) {
+			return true
+		}
+	}
+	return false
+}
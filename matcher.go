@@ -2,6 +2,7 @@ package lion
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/celrenheit/lion/internal/matcher"
@@ -10,8 +11,9 @@ import (
 // RegisterMatcher registers and matches routes to Handlers
 type registerMatcher interface {
 	Register(method, pattern string, handler http.Handler) *route
+	RegisterNotFound(pattern string, router *Router)
 	Match(*ctx, *http.Request) (*ctx, http.Handler)
-	Path(pattern string, params map[string]string) (string, error)
+	Path(pattern string, params map[string]interface{}) (string, error)
 }
 
 ////////////////////////////////////////////////////////////////////////////
@@ -22,8 +24,14 @@ var _ registerMatcher = (*pathMatcher)(nil)
 
 type pathMatcher struct {
 	matcher matcher.Matcher
+
+	// notFoundMatcher stores the groups with their own not found settings, it is created on demand
+	notFoundMatcher matcher.Matcher
 }
 
+// notFoundWildcardKey is the name of the wildcard parameter used to match every path under a group's pattern
+const notFoundWildcardKey = "lionNotFoundKey"
+
 func newPathMatcher() *pathMatcher {
 	cfg := &matcher.Config{
 		ParamChar:    ':',
@@ -47,85 +55,262 @@ func (d *pathMatcher) Register(method, pattern string, handler http.Handler) *ro
 	return rt.(*route)
 }
 
+// RegisterNotFound registers router as the not found scope of every unmatched path starting with pattern
+func (d *pathMatcher) RegisterNotFound(pattern string, router *Router) {
+	if d.notFoundMatcher == nil {
+		d.notFoundMatcher = matcher.Custom(&matcher.Config{
+			ParamChar:    ':',
+			WildcardChar: '*',
+			Separators:   "/.",
+			New: func() matcher.Store {
+				return &notFoundStore{}
+			},
+		})
+	}
+
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern != "" {
+		d.notFoundMatcher.Set(pattern, router, nil)
+	}
+	d.notFoundMatcher.Set(pattern+"/*"+notFoundWildcardKey, router, nil)
+}
+
 func (d *pathMatcher) Match(c *ctx, r *http.Request) (*ctx, http.Handler) {
-	p := cleanPath(r.URL.Path)
+	rawpath := requestPath(c, r)
+	encoded := c.router.encodedPathEnabled()
+	p := cleanPath(rawpath)
+	nparams := len(c.params)
 
 	c.tags[0] = r.Method
+	mc := c.matcherContext()
+
+	store, h, err := d.matcher.LookupWithContext(mc, p, c.tags)
+	if rawpath != "" && p != rawpath {
+		c.traceStep("clean", p, "cleaned path")
+		switch policyRouter(c, store).cleanPathPolicy() {
+		case CleanPathRedirect:
+			if err != matcher.ErrNotFound && err != matcher.ErrTSR {
+				c.traceResult(MatchRedirect, nil, p)
+				return c, pathRedirectHandler(p)
+			}
+		case CleanPathStrict:
+			c.traceStep("clean", rawpath, "strict policy, matching the path as is")
+			c.params = c.params[:nparams]
+			p = rawpath
+			if p[0] != '/' {
+				p = "/" + p
+			}
+			store, h, err = d.matcher.LookupWithContext(mc, p, c.tags)
+		}
+	}
 
-	h, err := d.matcher.GetWithContext(c, p, c.tags)
 	if err == matcher.ErrTSR {
+		alt := p + "/"
 		if p[len(p)-1] == '/' {
-			p = p[:len(p)-1]
-		} else {
-			p = p + "/"
+			alt = p[:len(p)-1]
 		}
 
-		return c, wrap(func(c Context) {
-			c.WithStatus(http.StatusMovedPermanently).
-				Redirect(p)
-		})
+		// Discard the params added while trying to match p
+		c.params = c.params[:nparams]
+		c.traceStep("tsr", alt, "trying with or without trailing slash")
+		store, h, err = d.matcher.LookupWithContext(mc, alt, c.tags)
+		switch policyRouter(c, store).trailingSlashPolicy() {
+		case TrailingSlashRedirect:
+			c.traceResult(MatchRedirect, nil, alt)
+			return c, pathRedirectHandler(alt)
+		case TrailingSlashNotFound:
+			c.traceStep("tsr", alt, "not found policy")
+			return c, d.notFound(c, p, nparams)
+		}
+		if err == matcher.ErrTSR {
+			err = matcher.ErrNotFound
+		}
+	}
+
+	// Paths differing by case are only looked up if a router ignores case
+	if err == matcher.ErrNotFound && c.router != nil && c.router.root().foldCase {
+		c.params = c.params[:nparams]
+		c.traceStep("case", p, "trying ignoring case")
+		store, h, err = d.matcher.LookupFoldWithContext(mc, p, c.tags)
+		switch policyRouter(c, store).pathCasePolicy() {
+		case CaseSensitive:
+			if err != matcher.ErrNotFound {
+				c.traceStep("case", p, "case sensitive policy")
+			}
+			store, h, err = nil, nil, matcher.ErrNotFound
+		case CaseRedirect:
+			if rt, ok := store.(*route); ok && err != matcher.ErrTSR {
+				params := make(map[string]string, len(c.params)-nparams)
+				for _, param := range c.params[nparams:] {
+					params[param.key] = param.val
+					if v, uerr := url.PathUnescape(param.val); encoded && uerr == nil {
+						params[param.key] = v
+					}
+				}
+				if canonical, perr := rt.Path(params); perr == nil {
+					c.traceResult(MatchRedirect, rt, canonical)
+					return c, pathRedirectHandler(canonical)
+				}
+			}
+		}
+		if err == matcher.ErrTSR {
+			err = matcher.ErrNotFound
+		}
 	}
 
 	if err == matcher.ErrNotFound {
-		return c, nil
+		return c, d.notFound(c, p, nparams)
+	}
+
+	if encoded {
+		unescapeParams(c, nparams)
 	}
 
 	if err == matcher.ErrTagsNotAllowed {
+		rt := store.(*route)
+		if rt.router != nil {
+			c.router = rt.router
+		}
+		allowed := rt.allowedMethods()
+		c.traceStep("method", r.Method, "not registered")
+		if len(allowed) == 0 { // There is no method allowed
+			return c, d.notFound(c, p, nparams)
+		}
 
 		// Automatic OPTIONS
-		if r.Method == OPTIONS {
-			hh := d.automaticOptionsHandler(c, p)
-			return c, hh
+		if r.Method == OPTIONS && rt.automaticOptions() {
+			c.traceResult(MatchAutomaticOptions, rt, "")
+			return c, automaticOptionsHandler{rt}
 		}
 
 		// Method not allowed
-		return c, wrap(func(c Context) {
-			c.Error(ErrorMethodNotAllowed)
-		})
+		c.traceResult(MatchMethodNotAllowed, rt, "")
+		return c, methodNotAllowedHandler{rt}
+	}
+
+	rt, ok := store.(*route)
+	if !ok {
+		return c, h.(http.Handler)
 	}
 
-	return c, h.(http.Handler)
+	rh, failed := rt.match(r.Method, r)
+	if rh == nil {
+		c.traceStep("predicates", r.Method, "no handler matches")
+		if h := failed.failureHandler(rt); h != nil {
+			if failed == predicateContentType {
+				c.traceResult(MatchUnsupportedMediaType, rt, "")
+			} else {
+				c.traceResult(MatchNotAcceptable, rt, "")
+			}
+			return c, h
+		}
+		return c, d.notFound(c, p, nparams)
+	}
+
+	c.traceStep("method", r.Method, "registered")
+	c.traceResult(MatchFound, rt, "")
+
+	// Per-router settings come from the router that registered the handler
+	if rh.router != nil {
+		c.router = rh.router
+	}
+	return c, rh.built
 }
 
-func (d *pathMatcher) prevalidation(method, pattern string) {
-	if len(pattern) == 0 || pattern[0] != '/' {
-		panicl("path must begin with '/' in path '" + pattern + "'")
+// policyRouter returns the router whose path policies apply to a request matching store
+func policyRouter(c *ctx, store matcher.Store) *Router {
+	if rt, ok := store.(*route); ok && rt.router != nil {
+		return rt.router
 	}
+	return c.router
+}
 
-	// Is http method allowed
-	if !isInStringSlice(allowedHTTPMethods[:], method) {
-		panicl("lion: invalid http method => %s\n\tShould be one of %v", method, allowedHTTPMethods)
+// notFound returns the not found handler of the group with the longest pattern matching path and sets it as the router of c.
+// It returns nil if there is none.
+func (d *pathMatcher) notFound(c *ctx, path string, nparams int) http.Handler {
+	c.traceResult(MatchNotFound, nil, "")
+	if d.notFoundMatcher == nil {
+		return nil
+	}
+
+	// Discard the params added while trying to match a route
+	c.params = c.params[:nparams]
+
+	v, err := d.notFoundMatcher.GetWithContext(c, path, nil)
+	if err == matcher.ErrTSR && len(path) > 1 {
+		v, err = d.notFoundMatcher.GetWithContext(c, path[:len(path)-1], nil)
+	}
+
+	if _, ok := c.ParamOk(notFoundWildcardKey); ok {
+		c.Remove(notFoundWildcardKey)
 	}
+
+	if err != nil {
+		c.params = c.params[:nparams]
+		return nil
+	}
+	c.router = v.(*Router)
+	return c.router.notFoundScope()
 }
 
-func (d *pathMatcher) automaticOptionsHandler(c *ctx, path string) http.Handler {
-	allowed := make([]string, 0, len(allowedHTTPMethods))
-	for _, method := range allowedHTTPMethods {
-		if method == OPTIONS {
-			continue
-		}
+// notFoundStore stores the router whose not found settings apply to the paths under its pattern
+type notFoundStore struct {
+	router *Router
+}
 
-		c.tags[0] = method
-		h, _ := d.matcher.GetWithContext(c, path, c.tags)
-		if h != nil {
-			allowed = append(allowed, method)
-		}
+func (s *notFoundStore) Set(value interface{}, tags matcher.Tags) {
+	if r, ok := value.(*Router); ok {
+		s.router = r
 	}
+}
 
-	if len(allowed) == 0 { // There is no method allowed
+func (s *notFoundStore) Get(tags matcher.Tags) interface{} {
+	if s.router == nil {
 		return nil
 	}
+	return s.router
+}
+
+func (d *pathMatcher) prevalidation(method, pattern string) {
+	if len(pattern) == 0 || pattern[0] != '/' {
+		panicl("path must begin with '/' in path '" + pattern + "'")
+	}
+
+	// Is http method allowed
+	if !isRegisteredMethod(method) {
+		panicl("invalid http method => %s\n\tShould be one of %v or registered using RegisterMethod", method, httpMethods())
+	}
+}
+
+// automaticOptionsHandler answers OPTIONS requests for routes that do not have an OPTIONS handler registered
+type automaticOptionsHandler struct {
+	route *route
+}
+
+func (h automaticOptionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(h.route.allowedMethods(), ", "))
+	w.WriteHeader(http.StatusOK)
+}
 
-	allowed = append(allowed, OPTIONS)
+// methodNotAllowedHandler sets the Allow header and calls the MethodNotAllowed handler of the router that registered the route
+type methodNotAllowedHandler struct {
+	route *route
+}
 
-	joined := strings.Join(allowed, ",")
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Accept", joined)
-		w.WriteHeader(http.StatusOK)
-	})
+func (h methodNotAllowedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(h.route.allowedMethods(), ", "))
+	if h.route.router == nil {
+		defaultMethodNotAllowedHandler.ServeHTTP(w, r)
+		return
+	}
+	h.route.router.methodNotAllowed().ServeHTTP(w, r)
 }
 
-func (d *pathMatcher) Path(pattern string, params map[string]string) (string, error) {
+var defaultMethodNotAllowedHandler = wrap(func(c Context) {
+	c.Error(ErrorMethodNotAllowed)
+})
+
+func (d *pathMatcher) Path(pattern string, params map[string]interface{}) (string, error) {
 	return d.matcher.Eval(pattern, params)
 }
 
@@ -2,6 +2,8 @@ package lion
 
 // Module represent an independent router entity.
 // It should be used to group routes and subroutes together.
+// A Module can declare its API version with a Version() string method, see Router.Version,
+// and that it is deprecated with a Deprecated() (time.Time, string) method, see Route.Deprecated.
 type Module interface {
 	Resource
 	Base() string
@@ -21,12 +23,25 @@ func (r *Router) Module(modules ...Module) {
 }
 
 func (r *Router) registerModule(m Module) {
-	g := r.Group(m.Base())
-	if req, ok := m.(moduleRequirements); ok {
+	// Check the requirements before registering anything
+	req, hasRequirements := m.(moduleRequirements)
+	if hasRequirements {
 		for _, dep := range req.Requires() {
 			if !r.hasNamed(dep) {
 				panic("Unmet middleware requirement for " + dep)
 			}
+		}
+	}
+
+	g := r.Group(m.Base())
+	if v, ok := m.(moduleVersion); ok {
+		g = g.Version(v.Version())
+	}
+	if dep, ok := m.(deprecatedResource); ok {
+		g.Deprecated(dep.Deprecated())
+	}
+	if hasRequirements {
+		for _, dep := range req.Requires() {
 			g.UseNamed(dep)
 		}
 	}
@@ -0,0 +1,148 @@
+package lion
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/celrenheit/lion/internal/matcher"
+)
+
+// ParamMatcherFunc reports whether a value extracted from the url is valid for a parameter type
+type ParamMatcherFunc func(value string) bool
+
+// ParamEncoderFunc converts a value to its url representation when building paths with RoutePathBuilder.WithValue
+type ParamEncoderFunc func(value interface{}) (string, error)
+
+// DateLayout is the layout used by the date parameter type
+const DateLayout = "2006-01-02"
+
+// RegisterParamType registers a named parameter type that can be used in patterns using the '|' character.
+//
+// The following types are available by default:
+// 	int	/users/:id|int		an optionally signed integer
+// 	uuid	/orders/:ref|uuid	a uuid such as 6ba7b810-9dad-11d1-80b4-00c04fd430c8
+// 	slug	/posts/:title|slug	lowercase letters and digits separated by dashes
+// 	date	/events/:day|date	a date formatted as 2006-01-02
+//
+// The matcher function is used to match incoming requests: if it returns false the route does not match.
+// It is also used to validate params when building paths with Route.Path and RoutePathBuilder.
+// The encoder function is optional, it converts values passed to RoutePathBuilder.WithValue.
+//
+// Parameter types must be registered before being used in a pattern.
+func RegisterParamType(name string, match ParamMatcherFunc, encode ParamEncoderFunc) {
+	registerParamType(name, match, encode, "")
+}
+
+// registerParamType registers a parameter type with a sample value used by Router.Validate to build example paths
+func registerParamType(name string, match ParamMatcherFunc, encode ParamEncoderFunc, sample string) {
+	matcher.RegisterParamType(matcher.ParamType{
+		Name:   name,
+		Match:  match,
+		Encode: encode,
+		Sample: sample,
+	})
+}
+
+func init() {
+	registerParamType("int", isInt, encodeInt, "1")
+	registerParamType("uuid", isUUID, encodeUUID, "6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	registerParamType("slug", isSlug, encodeStringer, "x")
+	registerParamType("date", isDate, encodeDate, "2006-01-02")
+}
+
+func isInt(value string) bool {
+	if len(value) > 0 && value[0] == '-' {
+		value = value[1:]
+	}
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeInt(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v), nil
+	case int8, int16, int32, int64:
+		return fmt.Sprintf("%d", v), nil
+	case uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	}
+	return "", fmt.Errorf("cannot encode %T as an int", value)
+}
+
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !isHex(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func encodeUUID(value interface{}) (string, error) {
+	if b, ok := value.([16]byte); ok {
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+	}
+	return encodeStringer(value)
+}
+
+func isSlug(value string) bool {
+	if value == "" || value[0] == '-' || value[len(value)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case c == '-' && value[i-1] != '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse(DateLayout, value)
+	return err == nil
+}
+
+func encodeDate(value interface{}) (string, error) {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(DateLayout), nil
+	}
+	return encodeStringer(value)
+}
+
+var errNotStringer = errors.New("value should be a string or implement fmt.Stringer")
+
+func encodeStringer(value interface{}) (string, error) {
+	if s, ok := value.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return "", errNotStringer
+}
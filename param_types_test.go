@@ -0,0 +1,131 @@
+package lion
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/celrenheit/htest"
+	"github.com/celrenheit/lion/internal/matcher"
+)
+
+func TestTypedParamsMatching(t *testing.T) {
+	l := New()
+	l.GetFunc("/users/:id|int", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "int:%s", Param(r, "id"))
+	})
+	l.GetFunc("/users/me", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "me")
+	})
+	l.GetFunc("/users/*rest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "rest:%s", Param(r, "rest"))
+	})
+	l.GetFunc("/orders/:ref|uuid", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "uuid:%s", Param(r, "ref"))
+	})
+	l.GetFunc("/posts/:title|slug/comments", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "slug:%s", Param(r, "title"))
+	})
+	l.GetFunc("/events/:day|date/:page|int?", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "date:%s:%s", Param(r, "day"), Param(r, "page"))
+	})
+
+	tests := []struct {
+		path, body string
+		status     int
+	}{
+		{path: "/users/42", body: "int:42"},
+		{path: "/users/-42", body: "int:-42"},
+		{path: "/users/me", body: "me"},
+		{path: "/users/batman", body: "rest:batman"},
+		{path: "/users/42/posts", body: "rest:42/posts"},
+		{path: "/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8", body: "uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{path: "/orders/6ba7b810", status: http.StatusNotFound},
+		{path: "/posts/hello-world-2/comments", body: "slug:hello-world-2"},
+		{path: "/posts/Hello_World/comments", status: http.StatusNotFound},
+		{path: "/posts/hello--world/comments", status: http.StatusNotFound},
+		{path: "/events/2016-11-30", body: "date:2016-11-30:"},
+		{path: "/events/2016-11-30/2", body: "date:2016-11-30:2"},
+		{path: "/events/2016-13-30", status: http.StatusNotFound},
+		{path: "/events/2016-11-30/two", status: http.StatusNotFound},
+	}
+
+	test := htest.New(t, l)
+	for _, tt := range tests {
+		status := http.StatusOK
+		if tt.status != 0 {
+			status = tt.status
+		}
+		res := test.Get(tt.path).Do().ExpectStatus(status)
+		if tt.body != "" {
+			res.ExpectBody(tt.body)
+		}
+	}
+}
+
+func TestTypedParamsPath(t *testing.T) {
+	l := New()
+	users := l.Get("/users/:id|int", fakeHandler())
+	events := l.Get("/events/:day|date", fakeHandler())
+	orders := l.Get("/orders/:ref|uuid", fakeHandler())
+
+	if _, err := users.Path(mss{"id": "abc"}); err == nil {
+		t.Error("Path should error for an invalid int")
+	}
+
+	tests := []struct {
+		builder  RoutePathBuilder
+		expected string
+	}{
+		{users.WithParam("id", "42"), "/users/42"},
+		{users.Build().WithValue("id", 42), "/users/42"},
+		{users.Build().WithValue("id", int64(-7)), "/users/-7"},
+		{events.Build().WithValue("day", time.Date(2016, 11, 30, 0, 0, 0, 0, time.UTC)), "/events/2016-11-30"},
+		{orders.Build().WithValue("ref", [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}), "/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+	}
+
+	for _, test := range tests {
+		path, err := test.builder.Path()
+		if err != nil {
+			t.Error(err)
+		}
+		if path != test.expected {
+			t.Errorf("Incorrect path: got '%s' want '%s'", path, test.expected)
+		}
+	}
+
+	if _, err := users.Build().WithValue("id", 4.2).Path(); err == nil {
+		t.Error("Path should error for a value that cannot be encoded")
+	}
+}
+
+func TestRegisterParamType(t *testing.T) {
+	// The registry is global, restore it so that the other tests only see the built-in types
+	t.Cleanup(matcher.SaveParamTypes())
+
+	RegisterParamType("even", func(value string) bool {
+		return isInt(value) && (value[len(value)-1]-'0')%2 == 0
+	}, nil)
+
+	l := New()
+	l.Get("/even/:n|even", fakeHandler())
+
+	test := htest.New(t, l)
+	test.Get("/even/42").Do().ExpectStatus(http.StatusOK)
+	test.Get("/even/43").Do().ExpectStatus(http.StatusNotFound)
+
+	recv := catchPanic(func() {
+		l.Get("/unknown/:n|unknown", fakeHandler())
+	})
+	if recv == nil {
+		t.Error("Should panic for an unknown parameter type")
+	}
+
+	recv = catchPanic(func() {
+		l.Get("/even/:n|int/conflict", fakeHandler())
+	})
+	if recv == nil {
+		t.Error("Should panic for a conflicting parameter type")
+	}
+}
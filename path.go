@@ -0,0 +1,140 @@
+package lion
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// TrailingSlashPolicy defines how a request is handled when its path only differs from the path of a route by a trailing slash
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashRedirect redirects to the path of the route.
+	// The status code is 301 Moved Permanently for GET and HEAD requests and 308 Permanent Redirect otherwise
+	// so that clients keep the method and body of the request. This is the default.
+	TrailingSlashRedirect TrailingSlashPolicy = iota
+	// TrailingSlashServe serves the route without redirecting
+	TrailingSlashServe
+	// TrailingSlashNotFound handles the request as not found
+	TrailingSlashNotFound
+)
+
+// CleanPathPolicy defines how a request with a path that is not clean, such as //a/../b, is handled
+type CleanPathPolicy int
+
+const (
+	// CleanPathServe matches the cleaned path and serves the route without redirecting. This is the default.
+	CleanPathServe CleanPathPolicy = iota
+	// CleanPathRedirect redirects to the cleaned path if it matches a route.
+	// The status code is 301 Moved Permanently for GET and HEAD requests and 308 Permanent Redirect otherwise.
+	CleanPathRedirect
+	// CleanPathStrict matches the path as is
+	CleanPathStrict
+)
+
+// PathCasePolicy defines how a request is handled when its path only differs from the path of a route by the case of its static parts
+type PathCasePolicy int
+
+const (
+	// CaseSensitive handles the request as not found. This is the default.
+	CaseSensitive PathCasePolicy = iota
+	// CaseInsensitive serves the route. Param values are kept as sent.
+	CaseInsensitive
+	// CaseRedirect redirects to the path of the route with the case used to register it.
+	// The status code is 301 Moved Permanently for GET and HEAD requests and 308 Permanent Redirect otherwise.
+	CaseRedirect
+)
+
+// TrailingSlash sets how requests whose path only differs by a trailing slash from a route registered on this router
+// or its subrouters are handled:
+// 	l.TrailingSlash(lion.TrailingSlashServe)
+// 	l.Get("/users", listUsers) // GET /users/ is served by listUsers
+func (r *Router) TrailingSlash(policy TrailingSlashPolicy) {
+	r.trailingSlash = &policy
+}
+
+func (r *Router) trailingSlashPolicy() TrailingSlashPolicy {
+	if rr := r.nearest(func(rr *Router) bool { return rr.trailingSlash != nil }); rr != nil {
+		return *rr.trailingSlash
+	}
+	return TrailingSlashRedirect
+}
+
+// CleanPath sets how requests whose path is not clean are handled for the routes registered on this router or its subrouters.
+// The path is cleaned by removing duplicate slashes and resolving . and .. elements.
+// If the cleaned path does not match any route, the policy of the router receiving the request is used.
+func (r *Router) CleanPath(policy CleanPathPolicy) {
+	r.cleanPath = &policy
+}
+
+func (r *Router) cleanPathPolicy() CleanPathPolicy {
+	if rr := r.nearest(func(rr *Router) bool { return rr.cleanPath != nil }); rr != nil {
+		return *rr.cleanPath
+	}
+	return CleanPathServe
+}
+
+// PathCase sets how requests whose path only differs by case from a route registered on this router or its subrouters are handled:
+// 	l.PathCase(lion.CaseInsensitive)
+// 	l.Get("/users/:id", showUser) // GET /Users/Bruce is served by showUser with the param id set to Bruce
+// Only ASCII letters are compared ignoring case. Routes matching the exact path of the request always take precedence.
+func (r *Router) PathCase(policy PathCasePolicy) {
+	r.pathCase = &policy
+	if policy != CaseSensitive {
+		r.root().foldCase = true
+	}
+}
+
+func (r *Router) pathCasePolicy() PathCasePolicy {
+	if rr := r.nearest(func(rr *Router) bool { return rr.pathCase != nil }); rr != nil {
+		return *rr.pathCase
+	}
+	return CaseSensitive
+}
+
+// UseEncodedPath enables or disables matching the routes against the percent-encoded path of the request
+// instead of the decoded path. It is disabled by default.
+//
+// When enabled, an encoded slash does not separate segments and the values of params are decoded after matching:
+// 	l.UseEncodedPath(true)
+// 	l.Get("/files/:name", getFile) // GET /files/a%2Fb is served by getFile with the param name set to a/b
+//
+// The setting of the router receiving the request applies.
+func (r *Router) UseEncodedPath(enabled bool) {
+	r.encodedPath = &enabled
+}
+
+func (r *Router) encodedPathEnabled() bool {
+	if rr := r.nearest(func(rr *Router) bool { return rr.encodedPath != nil }); rr != nil {
+		return *rr.encodedPath
+	}
+	return false
+}
+
+// requestPath returns the path of req used to match the routes
+func requestPath(c *ctx, req *http.Request) string {
+	if c.router.encodedPathEnabled() {
+		return req.URL.EscapedPath()
+	}
+	return req.URL.Path
+}
+
+// unescapeParams decodes the values of the params added to c after the first nparams
+func unescapeParams(c *ctx, nparams int) {
+	for i := nparams; i < len(c.params); i++ {
+		if v, err := url.PathUnescape(c.params[i].val); err == nil {
+			c.params[i].val = v
+		}
+	}
+}
+
+// pathRedirectHandler redirects to a path keeping the query string of the request
+type pathRedirectHandler string
+
+func (p pathRedirectHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	location := string(p)
+	if req.URL.RawQuery != "" {
+		location += "?" + req.URL.RawQuery
+	}
+	http.Redirect(w, req, location, redirectCode(http.StatusMovedPermanently, req.Method))
+}
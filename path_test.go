@@ -0,0 +1,193 @@
+package lion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celrenheit/htest"
+)
+
+func TestPathPolicies(t *testing.T) {
+	l := New()
+	l.Get("/users", fakeHandlerWithBody("users"))
+	l.Post("/users", fakeHandlerWithBody("created"))
+	l.Get("/a/b", fakeHandlerWithBody("ab"))
+
+	serve := l.Group("/serve")
+	serve.TrailingSlash(TrailingSlashServe)
+	serve.CleanPath(CleanPathRedirect)
+	serve.Get("/items", fakeHandlerWithBody("items"))
+	serve.Get("/a/b", fakeHandlerWithBody("serve ab"))
+
+	strict := l.Group("/strict")
+	strict.TrailingSlash(TrailingSlashNotFound)
+	strict.CleanPath(CleanPathStrict)
+	strict.Get("/items", fakeHandlerWithBody("strict items"))
+
+	tests := []struct {
+		method, path string
+		status       int
+		location     string
+		body         string
+	}{
+		{method: "GET", path: "/users/", status: http.StatusMovedPermanently, location: "/users"},
+		{method: "GET", path: "/users/?page=2", status: http.StatusMovedPermanently, location: "/users?page=2"},
+		{method: "POST", path: "/users/", status: http.StatusPermanentRedirect, location: "/users"},
+		{method: "GET", path: "//a/../a/b", status: http.StatusOK, body: "ab"},
+		{method: "GET", path: "/serve/items/", status: http.StatusOK, body: "items"},
+		{method: "GET", path: "/serve//a/./b?x=1", status: http.StatusMovedPermanently, location: "/serve/a/b?x=1"},
+		{method: "POST", path: "/serve//items", status: http.StatusPermanentRedirect, location: "/serve/items"},
+		{method: "GET", path: "/strict/items/", status: http.StatusNotFound},
+		{method: "GET", path: "/strict//items", status: http.StatusNotFound},
+		{method: "GET", path: "/strict/items", status: http.StatusOK, body: "strict items"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d but got %d", test.method, test.path, test.status, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s %s: expected location '%s' but got '%s'", test.method, test.path, test.location, location)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body '%s' but got '%s'", test.method, test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestPathCase(t *testing.T) {
+	l := New()
+	l.GET("/users/:id", func(c Context) {
+		c.String("user %s", c.Param("id"))
+	})
+	l.Get("/About", fakeHandlerWithBody("about"))
+
+	insensitive := l.Group("/legacy")
+	insensitive.PathCase(CaseInsensitive)
+	insensitive.GET("/Items/:name/details", func(c Context) {
+		c.String("item %s", c.Param("name"))
+	})
+
+	redirect := l.Group("/api")
+	redirect.PathCase(CaseRedirect)
+	redirect.Get("/users/:id/posts", fakeHandlerWithBody("posts"))
+	redirect.Get("/files/*path", fakeHandlerWithBody("file"))
+
+	tests := []struct {
+		method, path string
+		status       int
+		location     string
+		body         string
+	}{
+		{method: "GET", path: "/users/Bruce", status: http.StatusOK, body: "user Bruce"},
+		{method: "GET", path: "/Users/Bruce", status: http.StatusNotFound},
+		{method: "GET", path: "/about", status: http.StatusNotFound},
+		{method: "GET", path: "/legacy/items/Batman/DETAILS", status: http.StatusOK, body: "item Batman"},
+		{method: "GET", path: "/LEGACY/Items/Batman/details", status: http.StatusOK, body: "item Batman"},
+		{method: "GET", path: "/API/Users/Bruce/Posts?page=2", status: http.StatusMovedPermanently, location: "/api/users/Bruce/posts?page=2"},
+		{method: "POST", path: "/Api/users/42/posts", status: http.StatusPermanentRedirect, location: "/api/users/42/posts"},
+		{method: "GET", path: "/API/Files/Docs/README.md", status: http.StatusMovedPermanently, location: "/api/files/Docs/README.md"},
+		{method: "GET", path: "/api/users/42/posts", status: http.StatusOK, body: "posts"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d but got %d", test.method, test.path, test.status, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s %s: expected location '%s' but got '%s'", test.method, test.path, test.location, location)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body '%s' but got '%s'", test.method, test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestPathCaseBacktracking(t *testing.T) {
+	l := New()
+	l.PathCase(CaseInsensitive)
+	l.GET("/users/:id", func(c Context) {
+		c.String("user %s", c.Param("id"))
+	})
+	l.GET("/Users/:id/posts", func(c Context) {
+		c.String("posts %s", c.Param("id"))
+	})
+
+	test := htest.New(t, l)
+	test.Get("/Users/Bob").Do().ExpectStatus(http.StatusOK).ExpectBody("user Bob")
+	test.Get("/users/bob/POSTS").Do().ExpectStatus(http.StatusOK).ExpectBody("posts bob")
+	test.Get("/USERS/bob/posts").Do().ExpectStatus(http.StatusOK).ExpectBody("posts bob")
+	test.Get("/users/bob/comments").Do().ExpectStatus(http.StatusNotFound)
+
+	// The lookup ignoring case is skipped if no router ignores case
+	sensitive := New()
+	sensitive.Get("/users", fakeHandler())
+	trace := sensitive.Explain(httptest.NewRequest("GET", "/Users", nil))
+	for _, step := range trace.Steps {
+		if step.Kind == "case" {
+			t.Errorf("Unexpected step %v", step)
+		}
+	}
+}
+
+func TestUseEncodedPath(t *testing.T) {
+	l := New()
+	l.UseEncodedPath(true)
+	l.GET("/files/:name", func(c Context) {
+		c.String("file %s", c.Param("name"))
+	}).WithName("file")
+	l.GET("/files/:name/raw", func(c Context) {
+		c.String("raw %s", c.Param("name"))
+	})
+	l.GET("/static/*path", func(c Context) {
+		c.String("static %s", c.Param("path"))
+	}).WithName("static")
+
+	tests := []struct {
+		path, body string
+	}{
+		{path: "/files/a%2Fb", body: "file a/b"},
+		{path: "/files/a%2Fb/raw", body: "raw a/b"},
+		{path: "/files/hello%20world", body: "file hello world"},
+		{path: "/static/css/a%2Fb.css", body: "static css/a/b.css"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d but got %d", test.path, http.StatusOK, w.Code)
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected body '%s' but got '%s'", test.path, test.body, w.Body.String())
+		}
+	}
+
+	path, err := l.Route("file").Path(map[string]string{"name": "a/b c"})
+	if err != nil || path != "/files/a%2Fb%20c" {
+		t.Errorf("Route.Path: expected /files/a%%2Fb%%20c but got %s, %v", path, err)
+	}
+
+	path, err = l.Route("static").Path(map[string]string{"path": "css/a b.css"})
+	if err != nil || path != "/static/css/a%20b.css" {
+		t.Errorf("Route.Path: expected /static/css/a%%20b.css but got %s, %v", path, err)
+	}
+
+	// Paths built with Route.Path are matched with the same param values
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/files/a%2Fb%20c", nil))
+	if w.Body.String() != "file a/b c" {
+		t.Errorf("expected the param to round trip but got '%s'", w.Body.String())
+	}
+}
@@ -0,0 +1,216 @@
+package lion
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// predicateKind defines the response sent when a predicate is the only reason a request does not match a route
+type predicateKind int
+
+const (
+	predicateRequest     predicateKind = iota // 404 Not Found
+	predicateVersion                          // 406 Not Acceptable with a Vary header
+	predicateAccept                           // 406 Not Acceptable
+	predicateContentType                      // 415 Unsupported Media Type
+)
+
+// predicate is a condition on the request that is evaluated after the route has been found in the tree
+type predicate struct {
+	kind  predicateKind
+	match func(req *http.Request) bool
+}
+
+var (
+	notAcceptableHandler = wrap(func(c Context) {
+		c.Error(ErrorNotAcceptable)
+	})
+	unsupportedMediaTypeHandler = wrap(func(c Context) {
+		c.Error(ErrorUnsupportedMediaType)
+	})
+)
+
+// failureHandler returns the handler responding to a request for which predicates of this kind failed.
+// It returns nil if the request should be handled as not found.
+func (k predicateKind) failureHandler(rt *route) http.Handler {
+	switch k {
+	case predicateVersion:
+		return varyHandler{router: rt.router, next: notAcceptableHandler}
+	case predicateAccept:
+		return notAcceptableHandler
+	case predicateContentType:
+		return unsupportedMediaTypeHandler
+	}
+	return nil
+}
+
+func (r *route) Headers(pairs ...string) Route {
+	keys, values := splitPairs("Headers", pairs)
+	return r.addPredicate(predicate{
+		kind: predicateRequest,
+		match: func(req *http.Request) bool {
+			for i, key := range keys {
+				if !matchValues(req.Header[key], values[i]) {
+					return false
+				}
+			}
+			return true
+		},
+	})
+}
+
+func (r *route) Queries(pairs ...string) Route {
+	keys, values := splitPairs("Queries", pairs)
+	return r.addPredicate(predicate{
+		kind: predicateRequest,
+		match: func(req *http.Request) bool {
+			query := req.URL.Query()
+			for i, key := range keys {
+				if !matchValues(query[key], values[i]) {
+					return false
+				}
+			}
+			return true
+		},
+	})
+}
+
+func (r *route) Schemes(schemes ...string) Route {
+	return r.addPredicate(predicate{
+		kind: predicateRequest,
+		match: func(req *http.Request) bool {
+			scheme := requestScheme(req)
+			for _, s := range schemes {
+				if strings.EqualFold(s, scheme) {
+					return true
+				}
+			}
+			return false
+		},
+	})
+}
+
+func (r *route) Consumes(mediatypes ...string) Route {
+	ranges := parseAccept(strings.Join(mediatypes, ","))
+	return r.addPredicate(predicate{
+		kind: predicateContentType,
+		match: func(req *http.Request) bool {
+			mediatype, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil {
+				return false
+			}
+			for _, mr := range ranges {
+				if mr.matches(mediatype) {
+					return true
+				}
+			}
+			return false
+		},
+	})
+}
+
+func (r *route) Produces(mediatypes ...string) Route {
+	return r.addPredicate(predicate{
+		kind: predicateAccept,
+		match: func(req *http.Request) bool {
+			return negotiateMediaType(req.Header.Get("Accept"), mediatypes...) != ""
+		},
+	})
+}
+
+// addPredicate adds p to the handlers added by the last registration on this route
+func (r *route) addPredicate(p predicate) Route {
+	if len(r.latest) == 0 {
+		panicl("predicates should be added after registering a handler on route %s", r.pattern)
+	}
+	for _, rh := range r.latest {
+		rh.predicates = append(rh.predicates, p)
+		r.restoreReplaced(rh)
+	}
+	return r
+}
+
+// match returns the handler registered for method whose predicates are satisfied by req.
+// Handlers with predicates are tried first, in the order they were registered, then the handler without predicates.
+// If there is none, it returns the kind of predicate that determines the response.
+func (r *route) match(method string, req *http.Request) (*routeHandler, predicateKind) {
+	rhs := r.variants[method]
+	if len(rhs) == 1 && len(rhs[0].predicates) == 0 {
+		return rhs[0], 0
+	}
+
+	var fallback *routeHandler
+	closest := predicateRequest
+	for _, rh := range rhs {
+		if len(rh.predicates) == 0 {
+			fallback = rh
+			continue
+		}
+
+		kind, ok := rh.check(req)
+		if ok {
+			return rh, 0
+		}
+		if kind > closest {
+			closest = kind
+		}
+	}
+
+	return fallback, closest
+}
+
+// check evaluates all the predicates of the handler.
+// If some fail, it returns the kind of the one that should determine the response.
+func (rh *routeHandler) check(req *http.Request) (predicateKind, bool) {
+	ok := true
+	failed := predicateContentType
+	for _, p := range rh.predicates {
+		if !p.match(req) {
+			ok = false
+			if p.kind < failed {
+				failed = p.kind
+			}
+		}
+	}
+	return failed, ok
+}
+
+func splitPairs(fn string, pairs []string) (keys, values []string) {
+	if len(pairs)%2 != 0 {
+		panicl("%s expects key/value pairs but got %d arguments", fn, len(pairs))
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+		values = append(values, pairs[i+1])
+	}
+	if fn == "Headers" {
+		for i := range keys {
+			keys[i] = http.CanonicalHeaderKey(keys[i])
+		}
+	}
+	return
+}
+
+// matchValues reports whether expected is one of values. An empty expected value only requires values to be present.
+func matchValues(values []string, expected string) bool {
+	if expected == "" {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if v == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func requestScheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return req.URL.Scheme
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,252 @@
+package lion
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+const (
+	contentTypeProblemJSON = "application/problem+json"
+	contentTypeProblemXML  = "application/problem+xml"
+
+	problemXMLNamespace = "urn:ietf:rfc:7807"
+)
+
+// Problem is an error response as defined by RFC 7807 (https://tools.ietf.org/html/rfc7807).
+// It implements HTTPError, Context.Error renders it as application/problem+json
+// or as application/problem+xml if the client prefers XML:
+//		 c.Error(&lion.Problem{
+//		 	Type:       "https://example.com/probs/out-of-credit",
+//		 	Title:      "You do not have enough credit.",
+//		 	StatusCode: http.StatusForbidden,
+//		 	Detail:     "Your current balance is 30, but that costs 50.",
+//		 	Extensions: map[string]interface{}{"balance": 30},
+//		 })
+type Problem struct {
+	// Type is a URI reference that identifies the problem type. Defaults to "about:blank".
+	Type string
+	// Title is a short summary of the problem type. Defaults to the status text.
+	Title string
+	// StatusCode is the http status code, rendered as the status member. Defaults to 500.
+	StatusCode int
+	// Detail is an explanation specific to this occurrence of the problem.
+	Detail string
+	// Instance is a URI reference that identifies this occurrence of the problem.
+	Instance string
+	// Extensions are additional members added to the problem document.
+	Extensions map[string]interface{}
+}
+
+// NewProblem returns a Problem with the provided status and detail
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{StatusCode: status, Detail: detail}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.title()
+	}
+	return p.title() + ": " + p.Detail
+}
+
+// Status returns the status code of the problem.
+// It allows Problem to implement HTTPError.
+func (p *Problem) Status() int {
+	return p.status()
+}
+
+func (p *Problem) status() int {
+	if p.StatusCode == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.StatusCode
+}
+
+func (p *Problem) title() string {
+	if p.Title == "" {
+		return http.StatusText(p.status())
+	}
+	return p.Title
+}
+
+func (p *Problem) typ() string {
+	if p.Type == "" {
+		return "about:blank"
+	}
+	return p.Type
+}
+
+// members returns the members of the problem document. Extensions cannot override the standard members.
+func (p *Problem) members() map[string]interface{} {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.typ()
+	m["title"] = p.title()
+	m["status"] = p.status()
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	} else {
+		delete(m, "detail")
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	} else {
+		delete(m, "instance")
+	}
+	return m
+}
+
+// MarshalJSON encodes the problem document. Extensions are added as top-level members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.members())
+}
+
+// MarshalXML encodes the problem document using the RFC 7807 namespace.
+// Extensions are added as child elements sorted by name, see encodeXMLMember.
+func (p *Problem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Space: problemXMLNamespace, Local: "problem"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	members := p.members()
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := encodeXMLMember(e, k, members[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeXMLMember encodes a member of the problem document as described in Appendix A of RFC 7807:
+// objects are encoded as child elements sorted by name and the items of arrays as i elements.
+// Values other than strings, numbers and booleans are first converted to their JSON representation.
+func encodeXMLMember(e *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch v := value.(type) {
+	case nil:
+		return e.EncodeElement("", start)
+	case string, bool, json.Number, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return e.EncodeElement(fmt.Sprint(v), start)
+	case map[string]interface{}:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXMLMember(e, k, v[k]); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case []interface{}:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeXMLMember(e, "i", item); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return err
+	}
+	return encodeXMLMember(e, name, generic)
+}
+
+// render writes the problem as JSON or XML depending on the Accept header of the request
+func (p *Problem) render(c *ctx) error {
+	var (
+		b     []byte
+		err   error
+		ctype string
+	)
+
+	accept := c.Request().Header.Get("Accept")
+	switch negotiateMediaType(accept, contentTypeProblemJSON, "application/json", contentTypeProblemXML, "application/xml", "text/xml") {
+	case contentTypeProblemXML, "application/xml", "text/xml":
+		b, err = xml.Marshal(p)
+		ctype = contentTypeProblemXML
+	}
+	if b == nil || err != nil {
+		// JSON is used if the problem cannot be encoded as XML
+		b, err = json.Marshal(p)
+		ctype = contentTypeProblemJSON
+	}
+	if err != nil {
+		// The extensions cannot be encoded, the standard members are still sent
+		b, err = json.Marshal(&Problem{Type: p.Type, Title: p.Title, StatusCode: p.StatusCode, Detail: p.Detail, Instance: p.Instance})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.WithStatus(p.status())
+	return c.raw(b, ctype)
+}
+
+// ProblemDetails enables or disables rendering errors as RFC 7807 problem documents for the routes registered on this router and its subrouters.
+// When enabled, Context.Error renders HTTPErrors such as ErrorNotFound, ParamError, BindError and ValidationError as Problems.
+// Other errors are rendered as a 500 Internal Server Error problem without details.
+// Problems are always rendered as problem documents.
+// On a group, it also applies to the requests matching no route under the group's pattern.
+func (r *Router) ProblemDetails(enabled bool) {
+	r.problemDetails = &enabled
+	r.registerNotFoundScope()
+}
+
+func (r *Router) problemDetailsEnabled() bool {
+	if rr := r.nearest(func(rr *Router) bool { return rr.problemDetails != nil }); rr != nil {
+		return *rr.problemDetails
+	}
+	return false
+}
+
+// toProblem converts err to a Problem
+func toProblem(err error) *Problem {
+	switch e := err.(type) {
+	case *Problem:
+		return e
+	case *ValidationError:
+		return &Problem{
+			StatusCode: e.Status(),
+			Detail:     "validation failed",
+			Extensions: map[string]interface{}{"errors": e.Errors},
+		}
+	case *PanicError:
+		return &Problem{StatusCode: e.Status()}
+	case HTTPError:
+		p := &Problem{StatusCode: e.Status()}
+		if detail := e.Error(); detail != p.title() {
+			p.Detail = detail
+		}
+		return p
+	}
+	return &Problem{StatusCode: http.StatusInternalServerError}
+}
@@ -0,0 +1,149 @@
+package lion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celrenheit/htest"
+)
+
+func TestProblem(t *testing.T) {
+	l := New()
+	l.GETE("/credit", func(c Context) error {
+		return &Problem{
+			Type:       "https://example.com/probs/out-of-credit",
+			Title:      "You do not have enough credit.",
+			StatusCode: http.StatusForbidden,
+			Detail:     "Your current balance is 30, but that costs 50.",
+			Instance:   "/account/12345/msgs/abc",
+			Extensions: map[string]interface{}{"balance": 30, "title": "ignored"},
+		}
+	})
+	l.GETE("/simple", func(c Context) error {
+		return NewProblem(http.StatusConflict, "already exists")
+	})
+
+	test := htest.New(t, l)
+	test.Get("/credit").Do().
+		ExpectStatus(http.StatusForbidden).
+		ExpectHeader("Content-Type", contentTypeProblemJSON).
+		ExpectBody(`{"balance":30,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`)
+
+	test.Get("/simple").Do().
+		ExpectStatus(http.StatusConflict).
+		ExpectBody(`{"detail":"already exists","status":409,"title":"Conflict","type":"about:blank"}`)
+
+	test.Get("/simple").AddHeader("Accept", "application/xml").Do().
+		ExpectStatus(http.StatusConflict).
+		ExpectHeader("Content-Type", contentTypeProblemXML).
+		ExpectBody(`<problem xmlns="urn:ietf:rfc:7807"><detail>already exists</detail><status>409</status><title>Conflict</title><type>about:blank</type></problem>`)
+
+	test.Get("/simple").AddHeader("Accept", "application/problem+xml;q=0.5, application/json").Do().
+		ExpectHeader("Content-Type", contentTypeProblemJSON)
+
+	l.GETE("/nested", func(c Context) error {
+		return &Problem{
+			StatusCode: http.StatusBadRequest,
+			Extensions: map[string]interface{}{
+				"errors": []map[string]interface{}{{"field": "name", "rules": []string{"required", "min"}}},
+				"limits": map[string]int{"max": 10},
+			},
+		}
+	})
+	test.Get("/nested").AddHeader("Accept", "application/xml").Do().
+		ExpectStatus(http.StatusBadRequest).
+		ExpectHeader("Content-Type", contentTypeProblemXML).
+		ExpectBody(`<problem xmlns="urn:ietf:rfc:7807"><errors><i><field>name</field><rules><i>required</i><i>min</i></rules></i></errors>` +
+			`<limits><max>10</max></limits><status>400</status><title>Bad Request</title><type>about:blank</type></problem>`)
+
+	l.GETE("/unencodable", func(c Context) error {
+		return &Problem{StatusCode: http.StatusBadRequest, Extensions: map[string]interface{}{"ch": make(chan int)}}
+	})
+	test.Get("/unencodable").AddHeader("Accept", "application/xml").Do().
+		ExpectStatus(http.StatusBadRequest).
+		ExpectHeader("Content-Type", contentTypeProblemJSON).
+		ExpectBody(`{"status":400,"title":"Bad Request","type":"about:blank"}`)
+
+	if err := NewProblem(http.StatusConflict, "already exists").Error(); err != "Conflict: already exists" {
+		t.Errorf("Unexpected error message: %s", err)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	l := New()
+	l.GETE("/text", func(c Context) error {
+		return ErrorForbidden
+	})
+
+	api := l.Group("/api")
+	api.ProblemDetails(true)
+	api.GETE("/forbidden", func(c Context) error {
+		return ErrorForbidden
+	})
+	api.GET("/users/:id", func(c Context) {
+		_, err := ParamInt(c, "id")
+		c.Error(err)
+	})
+	api.GETE("/internal", func(c Context) error {
+		return http.ErrBodyNotAllowed
+	})
+
+	test := htest.New(t, l)
+	test.Get("/text").Do().
+		ExpectStatus(http.StatusForbidden).
+		ExpectHeader("Content-Type", contentTypeTextPlain).
+		ExpectBody("Forbidden")
+
+	test.Get("/api/forbidden").Do().
+		ExpectStatus(http.StatusForbidden).
+		ExpectHeader("Content-Type", contentTypeProblemJSON).
+		ExpectBody(`{"status":403,"title":"Forbidden","type":"about:blank"}`)
+
+	test.Get("/api/users/abc").Do().
+		ExpectStatus(http.StatusBadRequest).
+		ExpectBody(`{"detail":"invalid url parameter \"id\": \"abc\" is not a valid int","status":400,"title":"Bad Request","type":"about:blank"}`)
+
+	test.Get("/api/internal").Do().
+		ExpectStatus(http.StatusInternalServerError).
+		ExpectBody(`{"status":500,"title":"Internal Server Error","type":"about:blank"}`)
+
+	test.Post("/api/forbidden").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Content-Type", contentTypeProblemJSON)
+
+	// Unmatched requests use the settings of the group they are under
+	test.Get("/api/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectHeader("Content-Type", contentTypeProblemJSON).
+		ExpectBody(`{"status":404,"title":"Not Found","type":"about:blank"}`)
+	test.Get("/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectBody("404 page not found\n")
+
+	l.ProblemDetails(true)
+	legacy := l.Group("/legacy")
+	legacy.ProblemDetails(false)
+	legacy.Get("/users", fakeHandler())
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/unknown", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"status":404,"title":"Not Found","type":"about:blank"}` {
+		t.Errorf("Unexpected not found response %d: %s", w.Code, w.Body.String())
+	}
+	test.Get("/legacy/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectBody("404 page not found\n")
+
+	// A NotFound handler defined by a parent is used by a group disabling problem details
+	l.NotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("custom"))
+	}))
+	test.Get("/legacy/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectBody("custom")
+	test.Get("/api/unknown").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectHeader("Content-Type", contentTypeProblemJSON)
+}
@@ -0,0 +1,197 @@
+package lion
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Redirect registers a route for all methods that redirects requests matching from to the location to.
+//
+// to can be a pattern referencing the named params of from, a url with such a pattern as its path or the name of a route:
+// 	l.Redirect("/u/:id", "/users/:id", http.StatusMovedPermanently)
+// 	l.Redirect("/old/:id", "https://example.com/users/:id", http.StatusFound)
+// 	l.Redirect("/profile/:id", "user", http.StatusMovedPermanently) // resolved with l.Route("user").Path
+// The query string of the request is appended to the location.
+//
+// code must be a redirection status code. It defaults to 301 Moved Permanently if it is 0.
+// Since clients may change the method of a 301 or 302 redirect to GET,
+// requests with methods other than GET and HEAD are redirected with 308 Permanent Redirect and 307 Temporary Redirect instead.
+func (r *Router) Redirect(from, to string, code int) Route {
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		panicl("Redirect from %s to %s: invalid redirect status code %d", from, to, code)
+	}
+	if to == "" {
+		panicl("Redirect from %s: empty target", from)
+	}
+
+	return r.Any(from, redirectHandler{router: r, to: to, code: code})
+}
+
+type redirectHandler struct {
+	router *Router
+	to     string
+	code   int
+}
+
+func (h redirectHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := C(req)
+
+	location, err := h.location(c)
+	if err != nil {
+		c.Error(ErrorInternalServer)
+		return
+	}
+	if req.URL.RawQuery != "" {
+		if strings.Contains(location, "?") {
+			location += "&" + req.URL.RawQuery
+		} else {
+			location += "?" + req.URL.RawQuery
+		}
+	}
+
+	http.Redirect(w, req, location, redirectCode(h.code, req.Method))
+}
+
+// location evaluates the target of the redirection with the params of the request
+func (h redirectHandler) location(c Context) (string, error) {
+	params := make(map[string]string)
+	for _, p := range c.(*ctx).params {
+		params[p.key] = p.val
+	}
+
+	if !isRedirectPattern(h.to) {
+		rt := h.router.root().Route(h.to)
+		if rt == nil {
+			return "", fmt.Errorf("route %s not found", h.to)
+		}
+		path, err := rt.Path(params)
+		return collapseLeadingSlashes(path), err
+	}
+
+	// Keep the scheme and host of absolute urls as is
+	prefix, pattern := "", h.to
+	if i := strings.Index(pattern, "://"); i >= 0 {
+		j := strings.IndexByte(pattern[i+3:], '/')
+		if j < 0 {
+			return pattern, nil
+		}
+		prefix, pattern = pattern[:i+3+j], pattern[i+3+j:]
+	}
+	// The query string of the target is not part of the pattern
+	query := ""
+	if i := strings.IndexByte(pattern, '?'); i >= 0 {
+		pattern, query = pattern[:i], pattern[i:]
+	}
+
+	values := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		values[k] = v
+	}
+	path, err := h.router.root().hostrm.Register(h.router.host).Path(pattern, values)
+	if err != nil {
+		return "", err
+	}
+	if prefix == "" {
+		path = collapseLeadingSlashes(path)
+	}
+	return prefix + path + query, nil
+}
+
+// collapseLeadingSlashes replaces the slashes and backslashes at the beginning of path with a single slash.
+// Otherwise, a param such as "/evil.com" would turn a relative location into //evil.com, which clients read as another host.
+func collapseLeadingSlashes(path string) string {
+	i := 0
+	for i < len(path) && (path[i] == '/' || path[i] == '\\') {
+		i++
+	}
+	if i <= 1 {
+		return path
+	}
+	return "/" + path[i:]
+}
+
+// isRedirectPattern reports whether to is a pattern or a url rather than a route name
+func isRedirectPattern(to string) bool {
+	return strings.HasPrefix(to, "/") || strings.Contains(to, "://")
+}
+
+// redirectCode returns the status code preserving the method and body of the request for methods other than GET and HEAD
+func redirectCode(code int, method string) int {
+	if method == GET || method == HEAD {
+		return code
+	}
+	switch code {
+	case http.StatusMovedPermanently:
+		return http.StatusPermanentRedirect
+	case http.StatusFound:
+		return http.StatusTemporaryRedirect
+	}
+	return code
+}
+
+// Alias registers pattern as another path for the route named routeName.
+// Requests matching pattern are served by the handlers of the route, as they are built for it,
+// so pattern should define the same params:
+// 	l.GET("/users/:id", showUser).WithName("user")
+// 	l.Alias("/members/:id", "user")
+// Handlers added to the route after calling Alias are served too.
+// It panics if there is no route named routeName.
+func (r *Router) Alias(pattern, routeName string) Route {
+	target, ok := r.root().Route(routeName).(*route)
+	if !ok {
+		panicl("Alias %s: route %s not found", pattern, routeName)
+	}
+
+	h := aliasHandler{target}
+	var (
+		rt     *route
+		latest []*routeHandler
+	)
+	for _, method := range httpMethods() {
+		// The handlers of the target are already built with their middlewares
+		rt = r.handle(method, pattern, h, nil)
+		latest = append(latest, rt.latest...)
+	}
+	rt.latest = latest
+	return rt
+}
+
+// aliasHandler serves requests with the handlers of route
+type aliasHandler struct {
+	route *route
+}
+
+func (h aliasHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := C(req).(*ctx)
+	rt := h.route
+
+	if len(rt.variants[req.Method]) == 0 {
+		if req.Method == OPTIONS && len(rt.Methods()) > 0 && rt.automaticOptions() {
+			automaticOptionsHandler{rt}.ServeHTTP(w, req)
+			return
+		}
+		methodNotAllowedHandler{rt}.ServeHTTP(w, req)
+		return
+	}
+
+	rh, failed := rt.match(req.Method, req)
+	if rh == nil {
+		if fh := failed.failureHandler(rt); fh != nil {
+			fh.ServeHTTP(w, req)
+			return
+		}
+		c.router.notFound(w, req)
+		return
+	}
+
+	if rh.router != nil {
+		c.router = rh.router
+	}
+	rh.built.ServeHTTP(w, req)
+}
@@ -0,0 +1,127 @@
+package lion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterRedirect(t *testing.T) {
+	l := New()
+	l.GET("/users/:id", func(c Context) {
+		c.String("user %s", c.Param("id"))
+	}).WithName("user")
+	l.Get("/posts/:id", fakeHandler()).WithName("post")
+
+	l.Redirect("/u/:id", "/users/:id", http.StatusMovedPermanently)
+	l.Redirect("/tmp/:id", "/users/:id?from=tmp", http.StatusFound)
+	l.Redirect("/p/:id", "post", 0)
+	l.Redirect("/ext/:id", "https://example.com/users/:id", http.StatusSeeOther)
+	l.Redirect("/broken/:name", "/users/:id", http.StatusFound)
+
+	tests := []struct {
+		method, path string
+		status       int
+		location     string
+	}{
+		{method: "GET", path: "/u/42", status: http.StatusMovedPermanently, location: "/users/42"},
+		{method: "HEAD", path: "/u/42", status: http.StatusMovedPermanently, location: "/users/42"},
+		{method: "GET", path: "/u/42?tab=posts&page=2", status: http.StatusMovedPermanently, location: "/users/42?tab=posts&page=2"},
+		{method: "POST", path: "/u/42", status: http.StatusPermanentRedirect, location: "/users/42"},
+		{method: "GET", path: "/tmp/42?page=2", status: http.StatusFound, location: "/users/42?from=tmp&page=2"},
+		{method: "PUT", path: "/tmp/42", status: http.StatusTemporaryRedirect, location: "/users/42?from=tmp"},
+		{method: "GET", path: "/p/7", status: http.StatusMovedPermanently, location: "/posts/7"},
+		{method: "DELETE", path: "/p/7", status: http.StatusPermanentRedirect, location: "/posts/7"},
+		{method: "POST", path: "/ext/42", status: http.StatusSeeOther, location: "https://example.com/users/42"},
+		{method: "GET", path: "/broken/x", status: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d but got %d", test.method, test.path, test.status, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s %s: expected location '%s' but got '%s'", test.method, test.path, test.location, location)
+		}
+	}
+
+	// The params of the request cannot redirect to another host
+	strict := New()
+	strict.CleanPath(CleanPathStrict)
+	strict.Redirect("/old/*path", "/*path", 0)
+	strict.Redirect("/bs/*path", "/*path", 0)
+	for path, location := range map[string]string{
+		"/old/a/b":          "/a/b",
+		"/old//evil.com":    "/evil.com",
+		"/old///evil.com/x": "/evil.com/x",
+		"/bs/%5Cevil.com":   "/%5Cevil.com",
+	} {
+		w := httptest.NewRecorder()
+		strict.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if got := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || got != location {
+			t.Errorf("GET %s: expected a redirection to '%s' but got %d '%s'", path, location, w.Code, got)
+		}
+	}
+
+	recv := catchPanic(func() {
+		l.Redirect("/invalid", "/users", http.StatusOK)
+	})
+	if recv == nil {
+		t.Error("Redirect should panic with a non redirection status code")
+	}
+}
+
+func TestAlias(t *testing.T) {
+	l := New()
+	api := l.Group("/api", MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Api", "true")
+			next.ServeHTTP(w, r)
+		})
+	}))
+	api.GET("/users/:id", func(c Context) {
+		c.String("user %s", c.Param("id"))
+	}).WithName("user")
+
+	l.Alias("/members/:id", "user")
+	api.PUT("/users/:id", func(c Context) {
+		c.String("updated %s", c.Param("id"))
+	})
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{method: "GET", path: "/members/42", status: http.StatusOK, body: "user 42"},
+		{method: "PUT", path: "/members/42", status: http.StatusOK, body: "updated 42"},
+		{method: "POST", path: "/members/42", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d but got %d", test.method, test.path, test.status, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body '%s' but got '%s'", test.method, test.path, test.body, w.Body.String())
+		}
+		if test.status == http.StatusOK && w.Header().Get("X-Api") != "true" {
+			t.Errorf("%s %s: the middlewares of the route should be applied", test.method, test.path)
+		}
+	}
+
+	recv := catchPanic(func() {
+		l.Alias("/unknown", "unknown")
+	})
+	if recv == nil {
+		t.Error("Alias should panic if the route does not exist")
+	}
+}
@@ -0,0 +1,139 @@
+package lion
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+)
+
+// Renderer encodes data in a specific format. Renderers are used by Negotiate.
+type Renderer interface {
+	// ContentType returns the value of the Content-Type header such as "application/json; charset=utf-8"
+	ContentType() string
+	Render(w io.Writer, data interface{}) error
+}
+
+// JSONRenderer renders data as JSON
+type JSONRenderer struct{}
+
+// ContentType returns application/json
+func (JSONRenderer) ContentType() string { return contentTypeJSON }
+
+// Render encodes data as JSON
+func (JSONRenderer) Render(w io.Writer, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// XMLRenderer renders data as XML
+type XMLRenderer struct{}
+
+// ContentType returns application/xml
+func (XMLRenderer) ContentType() string { return contentTypeXML }
+
+// Render encodes data as XML
+func (XMLRenderer) Render(w io.Writer, data interface{}) error {
+	return xml.NewEncoder(w).Encode(data)
+}
+
+// TextRenderer renders data as plain text using fmt.Fprint
+type TextRenderer struct{}
+
+// ContentType returns text/plain
+func (TextRenderer) ContentType() string { return contentTypeTextPlain }
+
+// Render writes the default format of data
+func (TextRenderer) Render(w io.Writer, data interface{}) error {
+	_, err := fmt.Fprint(w, data)
+	return err
+}
+
+// HTMLRenderer renders data using an html template.
+// If Name is empty, Template is executed. Otherwise, the template with this name is executed.
+type HTMLRenderer struct {
+	Template *template.Template
+	Name     string
+}
+
+// ContentType returns text/html
+func (HTMLRenderer) ContentType() string { return contentTypeTextHTML }
+
+// Render executes the template with data
+func (r HTMLRenderer) Render(w io.Writer, data interface{}) error {
+	if r.Name == "" {
+		return r.Template.Execute(w, data)
+	}
+	return r.Template.ExecuteTemplate(w, r.Name, data)
+}
+
+// DefaultRenderers returns the renderers used if none have been set with Router.Renderers: JSON, XML and text.
+func DefaultRenderers() []Renderer {
+	return []Renderer{JSONRenderer{}, XMLRenderer{}, TextRenderer{}}
+}
+
+// Renderers sets the renderers used by Negotiate for the routes registered on this router and its subrouters.
+// They are given in order of preference: the first one is used if the client accepts any format.
+// 	l.Renderers(lion.JSONRenderer{}, lion.HTMLRenderer{Template: tmpl, Name: "index"})
+// Use DefaultRenderers to keep the default renderers:
+// 	l.Renderers(append(lion.DefaultRenderers(), csvRenderer{})...)
+func (r *Router) Renderers(renderers ...Renderer) {
+	r.renderers = renderers
+}
+
+func (r *Router) renderersFor() []Renderer {
+	if rr := r.nearest(func(rr *Router) bool { return rr.renderers != nil }); rr != nil {
+		return rr.renderers
+	}
+	return DefaultRenderers()
+}
+
+// Negotiate renders data using the renderer that best matches the Accept header of the request.
+// Quality values are taken into account, then the order of the router's renderers.
+// If no renderer is acceptable, it responds with 406 Not Acceptable.
+// 	return lion.Negotiate(c, users)
+func Negotiate(c Context, data interface{}) error {
+	renderers := DefaultRenderers()
+	lc, isCtx := c.(*ctx)
+	if isCtx && lc.router != nil {
+		renderers = lc.router.renderersFor()
+	}
+
+	offers := make([]string, len(renderers))
+	for i, r := range renderers {
+		mediatype, _, err := mime.ParseMediaType(r.ContentType())
+		if err != nil {
+			panicl("invalid content type %q for renderer %T", r.ContentType(), r)
+		}
+		offers[i] = mediatype
+	}
+
+	c.Header().Add("Vary", "Accept")
+
+	chosen := negotiateMediaType(c.Request().Header.Get("Accept"), offers...)
+	for i, offer := range offers {
+		if offer != chosen {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := renderers[i].Render(&buf, data); err != nil {
+			return err
+		}
+		if isCtx {
+			return lc.raw(buf.Bytes(), renderers[i].ContentType())
+		}
+		c.Header().Set("Content-Type", renderers[i].ContentType())
+		_, err := c.Write(buf.Bytes())
+		return err
+	}
+
+	return c.Error(ErrorNotAcceptable)
+}
@@ -0,0 +1,113 @@
+package lion
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/celrenheit/htest"
+)
+
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string { return "text/csv" }
+
+func (csvRenderer) Render(w io.Writer, data interface{}) error {
+	p := data.(renderPerson)
+	_, err := fmt.Fprintf(w, "%s,%d", p.Name, p.Age)
+	return err
+}
+
+type renderPerson struct {
+	XMLName struct{} `json:"-" xml:"person"`
+	Name    string   `json:"name" xml:"name"`
+	Age     int      `json:"age" xml:"age"`
+}
+
+func (p renderPerson) String() string {
+	return p.Name
+}
+
+func TestNegotiate(t *testing.T) {
+	person := renderPerson{Name: "Bruce", Age: 42}
+	handler := func(c Context) {
+		Negotiate(c, person)
+	}
+
+	l := New()
+	l.GET("/person", handler)
+
+	html := l.Group("/html")
+	html.Renderers(HTMLRenderer{Template: template.Must(template.New("person").Parse("<b>{{.Name}}</b>"))}, JSONRenderer{})
+	html.GET("/person", handler)
+
+	csv := l.Group("/csv")
+	csv.Renderers(append(DefaultRenderers(), csvRenderer{})...)
+	csv.GET("/person", handler)
+
+	tests := []struct {
+		path, accept string
+		status       int
+		ctype, body  string
+	}{
+		{path: "/person", ctype: contentTypeJSON, body: `{"name":"Bruce","age":42}`},
+		{path: "/person", accept: "*/*", ctype: contentTypeJSON, body: `{"name":"Bruce","age":42}`},
+		{path: "/person", accept: "application/xml", ctype: contentTypeXML, body: `<person><name>Bruce</name><age>42</age></person>`},
+		{path: "/person", accept: "text/*", ctype: contentTypeTextPlain, body: "Bruce"},
+		{path: "/person", accept: "application/json;q=0.5, text/plain;q=0.8", ctype: contentTypeTextPlain, body: "Bruce"},
+		{path: "/person", accept: "application/json;q=0, */*;q=0.1", ctype: contentTypeXML},
+		{path: "/person", accept: "image/png", status: http.StatusNotAcceptable, body: "Not Acceptable"},
+		{path: "/html/person", accept: "text/html,application/xhtml+xml,*/*;q=0.8", ctype: contentTypeTextHTML, body: "<b>Bruce</b>"},
+		{path: "/html/person", accept: "application/xml", status: http.StatusNotAcceptable},
+		{path: "/csv/person", accept: "text/csv", ctype: "text/csv", body: "Bruce,42"},
+	}
+
+	test := htest.New(t, l)
+	for _, tt := range tests {
+		status := http.StatusOK
+		if tt.status != 0 {
+			status = tt.status
+		}
+
+		req := test.Get(tt.path)
+		if tt.accept != "" {
+			req = req.AddHeader("Accept", tt.accept)
+		}
+		res := req.Do().ExpectStatus(status).ExpectHeader("Vary", "Accept")
+		if tt.ctype != "" {
+			res.ExpectHeader("Content-Type", tt.ctype)
+		}
+		if tt.body != "" {
+			res.ExpectBody(tt.body)
+		}
+	}
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		accept   string
+		offers   []string
+		expected string
+	}{
+		{"", []string{"application/json", "text/plain"}, "application/json"},
+		{"application/*+json", []string{"text/plain", "application/vnd.api+json"}, "application/vnd.api+json"},
+		{"application/*+json", []string{"application/json"}, ""},
+		// A vendor media type accepts the media type of its suffix
+		{"application/vnd.example.v2+json", []string{"text/plain", "application/json"}, "application/json"},
+		{"application/vnd.example+xml", []string{"application/json"}, ""},
+		{"application/json;q=0, */*", []string{"application/json", "text/plain"}, "text/plain"},
+		// The most specific range matching an offer gives its q value
+		{"*/*, application/json;q=0.1", []string{"application/json", "application/xml"}, "application/xml"},
+		{"text/*;q=0.5, text/html;q=0.1, */*;q=0.2", []string{"text/html", "text/plain", "image/png"}, "text/plain"},
+		{"*/*, application/json", []string{"application/xml", "application/json"}, "application/json"},
+		{"text/plain, application/json", []string{"application/json", "text/plain"}, "text/plain"},
+	}
+
+	for _, test := range tests {
+		if got := negotiateMediaType(test.accept, test.offers...); got != test.expected {
+			t.Errorf("Accept %q with offers %v: expected %q but got %q", test.accept, test.offers, test.expected, got)
+		}
+	}
+}
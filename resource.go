@@ -14,7 +14,8 @@ type resourceUses interface {
 	Uses() Middlewares
 }
 
-// Resource registers a Resource with the corresponding pattern
+// Resource registers a Resource with the corresponding pattern.
+// If the resource has a Deprecated() (time.Time, string) method, its routes are deprecated (see Route.Deprecated).
 func (r *Router) Resource(pattern string, resource Resource) {
 	sub := r.Group(pattern)
 
@@ -24,7 +25,11 @@ func (r *Router) Resource(pattern string, resource Resource) {
 		}
 	}
 
-	for _, m := range allowedHTTPMethods {
+	if dep, ok := resource.(deprecatedResource); ok {
+		sub.Deprecated(dep.Deprecated())
+	}
+
+	for _, m := range httpMethods() {
 		if hfn, ok := isHandlerFuncInResource(m, resource); ok {
 			s := sub.Subrouter()
 			if mws, ok := isMiddlewareInResource(m, resource); ok {
@@ -50,11 +55,13 @@ func isHandlerFuncInResource(m string, r Resource) (func(w http.ResponseWriter,
 	}
 
 	// ... or check for a contextual handler
-	cfn, ok := method.Interface().(func(Context))
-	if !ok {
-		return nil, false
+	switch cfn := method.Interface().(type) {
+	case func(Context):
+		return wrap(cfn).ServeHTTP, true
+	case func(Context) error:
+		return wrapE(cfn).ServeHTTP, true
 	}
-	return wrap(cfn).ServeHTTP, ok
+	return nil, false
 }
 
 // checks if there is a NameMiddlewares() Middlewares method available on the Resource r
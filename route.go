@@ -3,16 +3,11 @@ package lion
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/celrenheit/lion/internal/matcher"
 )
 
-// TODO: add this later
-// WithMethod adds a new handler to the corresponding HTTP method.
-// The handler will not be built with middlewares.
-// If you want to add middleware you should add them by yourself.
-// WithMethod(method string, handler http.Handler) Route
-
 // Routes is an slice of Route.
 // Check Routes.ByName or Routes.ByPattern to find out if it is useful to you
 type Routes []Route
@@ -92,6 +87,42 @@ type Route interface {
 	// Convenient alias for Build().WithParam()
 	// Calling this method will create a new RoutePathBuilder
 	WithParam(key, value string) RoutePathBuilder
+
+	// WithMethod adds a new handler to the corresponding HTTP method.
+	// The handler is built with the middlewares of the router that registered this route and with the route's own middlewares.
+	WithMethod(method string, handler http.Handler) Route
+
+	// Use adds middlewares that only apply to this route.
+	// They are run after the router's middlewares and apply to every HTTP method of the route, including the ones added later.
+	Use(middlewares ...Middleware) Route
+
+	// Headers, Queries, Schemes, Consumes and Produces add predicates to the handlers registered by the last call
+	// to a registration method (Get, Post, ..., WithMethod) that returned this route.
+	// They are evaluated after the route has been found, which allows several handlers for the same path and method:
+	//		 l.Get("/export", csvHandler).Queries("format", "csv")
+	//		 l.Get("/export", jsonHandler)
+	// Handlers with predicates are tried in the order they were registered, then the handler without predicates.
+	// If no handler matches, the response is 415 Unsupported Media Type if only Consumes failed,
+	// 406 Not Acceptable if only Produces failed and 404 Not Found otherwise.
+
+	// Headers requires headers with the given values, passed as key/value pairs. An empty value only requires the header to be set.
+	Headers(pairs ...string) Route
+	// Queries requires query string parameters with the given values, passed as key/value pairs. An empty value only requires the parameter to be set.
+	Queries(pairs ...string) Route
+	// Schemes requires one of the given url schemes such as "https"
+	Schemes(schemes ...string) Route
+	// Consumes requires the Content-Type of the request to match one of the given media types. Wildcards such as "application/*" are allowed.
+	Consumes(mediatypes ...string) Route
+	// Produces requires the Accept header of the request to accept one of the given media types
+	Produces(mediatypes ...string) Route
+
+	// Deprecated marks the route as deprecated. Responses get a Deprecation header,
+	// a Sunset header if sunset is not zero and a Link header with rel="successor-version" if link is not empty.
+	// The function set with Router.OnDeprecated is called each time the route is requested.
+	Deprecated(sunset time.Time, link string) Route
+
+	// Deprecation returns the deprecation of the route or nil if it is not deprecated
+	Deprecation() *Deprecation
 }
 
 type route struct {
@@ -99,15 +130,17 @@ type route struct {
 
 	pathMatcher registerMatcher
 
-	get     http.Handler
-	head    http.Handler
-	post    http.Handler
-	put     http.Handler
-	delete  http.Handler
-	trace   http.Handler
-	options http.Handler
-	connect http.Handler
-	patch   http.Handler
+	// router is the router that first registered this route.
+	router      *Router
+	middlewares Middlewares
+
+	// variants contains the handlers registered for each method.
+	// There can be several handlers for the same method if they have different predicates.
+	variants map[string][]*routeHandler
+	// latest contains the handlers added by the last registration. Predicates apply to them.
+	latest []*routeHandler
+
+	deprecation *Deprecation
 }
 
 func newRoute() *route {
@@ -124,15 +157,168 @@ func (r *route) WithPattern(pattern string) Route {
 	return r
 }
 
-func (r *route) withMethods(handler http.Handler, methods ...string) Route {
-	for _, method := range methods {
-		r.addHandler(method, handler)
-	}
+// handlerSource keeps track of the handler as registered by the user and of the router that registered it.
+// It is used to rebuild the middleware chain when the route's middlewares change.
+type handlerSource struct {
+	handler http.Handler
+	router  *Router
+}
+
+// routeHandler is a handler registered for a method of the route along with its predicates
+type routeHandler struct {
+	handlerSource
+	predicates  []predicate
+	version     string
+	deprecation *Deprecation // set if the router that registered the handler is deprecated
+
+	// built is the handler wrapped with the router's and route's middlewares
+	built http.Handler
+
+	// replaced is the handler without predicates replaced by this one when it was registered.
+	// It is restored if predicates are added to this handler.
+	replaced *routeHandler
+}
+
+func (r *route) WithMethod(method string, handler http.Handler) Route {
+	r.pathMatcher.Register(method, r.pattern, handler)
+	r.latest = []*routeHandler{r.register(r.router, method, handler)}
 	return r
 }
 
+func (r *route) Use(middlewares ...Middleware) Route {
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.rebuild()
+	return r
+}
+
+// rebuild builds the handlers of every method
+func (r *route) rebuild() {
+	for _, rhs := range r.variants {
+		for _, rh := range rhs {
+			r.build(rh)
+		}
+	}
+}
+
+// register sets the handler for the method and builds it with the router's and route's middlewares.
+// It replaces the handler without predicates of this method if there is one, until predicates are added to the new handler.
+func (r *route) register(router *Router, method string, handler http.Handler) *routeHandler {
+	src := handlerSource{handler: handler, router: router}
+
+	var rh *routeHandler
+	if version := router.apiVersion(); version != "" {
+		rh = r.setVersionHandler(method, version, src)
+	} else {
+		rh = r.setDefaultHandler(method, src)
+	}
+	rh.deprecation = router.deprecationFor()
+
+	r.build(rh)
+	return rh
+}
+
+// setVersionHandler replaces the source of the handler of method for version or adds a new one
+func (r *route) setVersionHandler(method, version string, src handlerSource) *routeHandler {
+	// Discard the handler set by Set before registration
+	if rh := r.defaultHandler(method); rh != nil && rh.router == nil {
+		r.removeHandler(method, rh)
+	}
+
+	for _, rh := range r.variants[method] {
+		if rh.version == version && len(rh.predicates) == 1 {
+			rh.handlerSource = src
+			return rh
+		}
+	}
+
+	if r.variants == nil {
+		r.variants = make(map[string][]*routeHandler)
+	}
+	rh := &routeHandler{
+		handlerSource: src,
+		version:       version,
+		predicates:    []predicate{versionPredicate(src.router, version)},
+	}
+	r.variants[method] = append(r.variants[method], rh)
+	return rh
+}
+
+func (r *route) removeHandler(method string, rh *routeHandler) {
+	rhs := r.variants[method]
+	for i := range rhs {
+		if rhs[i] == rh {
+			r.variants[method] = append(rhs[:i], rhs[i+1:]...)
+			return
+		}
+	}
+}
+
+// setDefaultHandler adds a new handler without predicates for method.
+// It replaces the current handler without predicates, which is restored by addPredicate if predicates are added to the new one.
+func (r *route) setDefaultHandler(method string, src handlerSource) *routeHandler {
+	rh := &routeHandler{handlerSource: src}
+	if prev := r.defaultHandler(method); prev != nil {
+		r.removeHandler(method, prev)
+		// The handler set by Set before registration is discarded
+		if prev.router != nil {
+			rh.replaced = prev
+		}
+	}
+
+	if r.variants == nil {
+		r.variants = make(map[string][]*routeHandler)
+	}
+	r.variants[method] = append(r.variants[method], rh)
+	return rh
+}
+
+// restoreReplaced adds back the handler without predicates replaced by rh
+func (r *route) restoreReplaced(rh *routeHandler) {
+	prev := rh.replaced
+	if prev == nil {
+		return
+	}
+	rh.replaced = nil
+
+	for method, rhs := range r.variants {
+		for _, v := range rhs {
+			if v == rh {
+				r.variants[method] = append(rhs, prev)
+				return
+			}
+		}
+	}
+}
+
+func (r *route) build(rh *routeHandler) {
+	handler := r.middlewares.BuildHandler(rh.handler)
+	if rh.router != nil {
+		handler = rh.router.buildMiddlewares(handler)
+	}
+	if rh.version != "" {
+		handler = varyHandler{router: rh.router, next: handler}
+	}
+	if d := r.deprecation; d != nil || rh.deprecation != nil {
+		if d == nil {
+			d = rh.deprecation
+		}
+		handler = deprecationHandler{route: r, router: rh.router, deprecation: d, next: handler}
+	}
+	rh.built = handler
+}
+
+// defaultHandler returns the handler without predicates registered for method
+func (r *route) defaultHandler(method string) *routeHandler {
+	for _, rh := range r.variants[method] {
+		if len(rh.predicates) == 0 {
+			return rh
+		}
+	}
+	return nil
+}
+
 func (r *route) Methods() (methods []string) {
-	for _, m := range allowedHTTPMethods {
+	for _, m := range httpMethods() {
 		if r.getHandler(m) != nil {
 			methods = append(methods, m)
 		}
@@ -140,6 +326,23 @@ func (r *route) Methods() (methods []string) {
 	return
 }
 
+// allowedMethods returns the methods that can be used in the Allow header.
+// OPTIONS is included if it is registered or if automatic OPTIONS are enabled.
+func (r *route) allowedMethods() []string {
+	methods := r.Methods()
+	if len(methods) > 0 && r.getHandler(OPTIONS) == nil && r.automaticOptions() {
+		methods = append(methods, OPTIONS)
+	}
+	return methods
+}
+
+func (r *route) automaticOptions() bool {
+	if r.router == nil {
+		return true
+	}
+	return r.router.automaticOptionsEnabled()
+}
+
 func (r *route) Host() string {
 	return r.host
 }
@@ -153,7 +356,11 @@ func (r *route) Pattern() string {
 }
 
 func (r *route) Path(params map[string]string) (string, error) {
-	return r.pathMatcher.Path(r.Pattern(), params)
+	values := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		values[k] = v
+	}
+	return r.pathMatcher.Path(r.Pattern(), values)
 }
 
 func (r *route) Handler(method string) http.Handler {
@@ -167,18 +374,21 @@ func (r *route) Set(value interface{}, tags matcher.Tags) {
 
 	method := tags[0]
 
-	var handler http.Handler
 	if value == nil {
-		handler = nil
-	} else {
-		if h, ok := value.(http.Handler); !ok {
-			panicl("Not handler")
-		} else {
-			handler = h
-		}
+		delete(r.variants, method)
+		return
+	}
+
+	handler, ok := value.(http.Handler)
+	if !ok {
+		panicl("Not handler")
 	}
 
-	r.addHandler(method, handler)
+	// Keep the handler registered by a Router, it is replaced by register
+	if rh := r.defaultHandler(method); rh != nil && rh.router != nil {
+		return
+	}
+	r.setDefaultHandler(method, handlerSource{handler: handler}).built = handler
 }
 
 func (r *route) Get(tags matcher.Tags) interface{} {
@@ -191,52 +401,15 @@ func (r *route) Get(tags matcher.Tags) interface{} {
 	return r.getHandler(method)
 }
 
-func (r *route) addHandler(method string, handler http.Handler) {
-	switch method {
-	case GET:
-		r.get = handler
-	case HEAD:
-		r.head = handler
-	case POST:
-		r.post = handler
-	case PUT:
-		r.put = handler
-	case DELETE:
-		r.delete = handler
-	case TRACE:
-		r.trace = handler
-	case OPTIONS:
-		r.options = handler
-	case CONNECT:
-		r.connect = handler
-	case PATCH:
-		r.patch = handler
-	}
-}
-
+// getHandler returns the handler without predicates for method or the first one registered
 func (r *route) getHandler(method string) http.Handler {
-	switch method {
-	case GET:
-		return r.get
-	case HEAD:
-		return r.head
-	case POST:
-		return r.post
-	case PUT:
-		return r.put
-	case DELETE:
-		return r.delete
-	case TRACE:
-		return r.trace
-	case OPTIONS:
-		return r.options
-	case CONNECT:
-		return r.connect
-	case PATCH:
-		return r.patch
-	default:
-		return nil
+	if rh := r.defaultHandler(method); rh != nil {
+		return rh.built
 	}
+	if rhs := r.variants[method]; len(rhs) > 0 {
+		return rhs[0].built
+	}
+	return nil
 }
 
 // RoutePathBuilder is a convenient utility to build path given each url parameters.
@@ -247,18 +420,24 @@ func (r *route) getHandler(method string) http.Handler {
 //		 // path should be equal to "/posts/123"
 type RoutePathBuilder interface {
 	WithParam(key, value string) RoutePathBuilder
+
+	// WithValue sets a param using a value that is not a string.
+	// It is converted using the encoder of the param's type (see RegisterParamType) or fmt.Sprint.
+	//		 route := router.Get("/posts/:day|date", postsHandler)
+	//		 path, err := route.Build().WithValue("day", time.Now()).Path()
+	WithValue(key string, value interface{}) RoutePathBuilder
 	Path() (string, error)
 }
 
 type routePathBuilder struct {
 	route  *route
-	params map[string]string
+	params map[string]interface{}
 }
 
 func (r *route) Build() RoutePathBuilder {
 	return &routePathBuilder{
 		route:  r,
-		params: make(map[string]string),
+		params: make(map[string]interface{}),
 	}
 }
 func (r *route) WithParam(key, value string) RoutePathBuilder {
@@ -270,6 +449,11 @@ func (r *routePathBuilder) WithParam(key, value string) RoutePathBuilder {
 	return r
 }
 
+func (r *routePathBuilder) WithValue(key string, value interface{}) RoutePathBuilder {
+	r.params[key] = value
+	return r
+}
+
 func (r *routePathBuilder) Path() (string, error) {
-	return r.route.Path(r.params)
+	return r.route.pathMatcher.Path(r.route.Pattern(), r.params)
 }
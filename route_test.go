@@ -1,6 +1,12 @@
 package lion
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celrenheit/htest"
+)
 
 func TestRouteGeneratePath(t *testing.T) {
 	l := New()
@@ -11,6 +17,8 @@ func TestRouteGeneratePath(t *testing.T) {
 		{"/a/:name/:n([0-9]+)", "a_name_n"},
 		{"/a/b/:dest/*path", "a_b_dest_path"},
 		{"/e/:file.:ext", "e_file_ext"},
+		{"/o/:year/:month?/:day?", "o_year_month_day"},
+		{"/oe/:file.:ext?", "oe_file_ext"},
 	}
 	for _, r := range register {
 		l.Get(r.pattern, fakeHandler()).WithName(r.name)
@@ -27,6 +35,12 @@ func TestRouteGeneratePath(t *testing.T) {
 		{routename: "a_name_n", params: mss{"name": "batman", "n": "1d23"}, expectedErr: true},
 		{routename: "a_b_dest_path", params: mss{"dest": "batman", "path": "subfolder/test/hello.jpeg"}, expectedPath: "/a/b/batman/subfolder/test/hello.jpeg"},
 		{routename: "e_file_ext", params: mss{"file": "test", "ext": "mp4"}, expectedPath: "/e/test.mp4"},
+		{routename: "o_year_month_day", params: mss{"year": "2016"}, expectedPath: "/o/2016"},
+		{routename: "o_year_month_day", params: mss{"year": "2016", "month": "11"}, expectedPath: "/o/2016/11"},
+		{routename: "o_year_month_day", params: mss{"year": "2016", "month": "11", "day": "30"}, expectedPath: "/o/2016/11/30"},
+		{routename: "o_year_month_day", params: mss{"month": "11"}, expectedErr: true},
+		{routename: "o_year_month_day", params: mss{"year": "2016", "day": "30"}, expectedErr: true},
+		{routename: "oe_file_ext", params: mss{"file": "test"}, expectedPath: "/oe/test"},
 	}
 
 	for _, test := range tests {
@@ -92,3 +106,131 @@ func TestGetRoutesSubrouter(t *testing.T) {
 		t.Errorf("Number of routes should be 8 but got %d: %v", got, l.Routes())
 	}
 }
+
+func TestRouteWithMethodAndUse(t *testing.T) {
+	l := New()
+	l.Use(fakeMW("Global", "true"))
+	api := l.Group("/api", fakeMW("Group", "true"))
+
+	rt := api.GetFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get"))
+	})
+	rt.Use(fakeMW("Route", "true")).
+		WithMethod(POST, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("post"))
+		}))
+
+	api.GetFunc("/other", func(w http.ResponseWriter, r *http.Request) {})
+
+	test := htest.New(t, l)
+	test.Get("/api/users").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Global", "true").
+		ExpectHeader("Group", "true").
+		ExpectHeader("Route", "true").
+		ExpectBody("get")
+
+	test.Post("/api/users").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Global", "true").
+		ExpectHeader("Group", "true").
+		ExpectHeader("Route", "true").
+		ExpectBody("post")
+
+	test.Get("/api/other").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Group", "true").
+		ExpectHeader("Route", "")
+
+	if methods := rt.Methods(); len(methods) != 2 {
+		t.Errorf("Route should have 2 methods but got %v", methods)
+	}
+}
+
+func TestRoutePredicates(t *testing.T) {
+	l := New()
+	l.Get("/export", fakeHandlerWithBody("csv")).Queries("format", "csv")
+	l.Get("/export", fakeHandlerWithBody("v2")).Headers("X-Api-Version", "2")
+	l.Get("/export", fakeHandlerWithBody("default"))
+
+	l.Post("/upload", fakeHandlerWithBody("json")).Consumes("application/json", "application/*+json")
+	l.Post("/upload", fakeHandlerWithBody("image")).Consumes("image/*")
+
+	l.Get("/report", fakeHandlerWithBody("pdf")).Produces("application/pdf")
+
+	l.Any("/secure", fakeHandlerWithBody("secure")).Schemes("https")
+	l.Get("/token", fakeHandlerWithBody("token")).Headers("Authorization", "")
+
+	tests := []struct {
+		method, path string
+		headers      map[string]string
+		status       int
+		body         string
+	}{
+		{method: "GET", path: "/export", body: "default"},
+		{method: "GET", path: "/export?format=csv", body: "csv"},
+		{method: "GET", path: "/export?format=xml", body: "default"},
+		{method: "GET", path: "/export", headers: map[string]string{"X-Api-Version": "2"}, body: "v2"},
+		{method: "POST", path: "/upload", headers: map[string]string{"Content-Type": "application/json; charset=utf-8"}, body: "json"},
+		{method: "POST", path: "/upload", headers: map[string]string{"Content-Type": "application/vnd.api+json"}, body: "json"},
+		{method: "POST", path: "/upload", headers: map[string]string{"Content-Type": "image/png"}, body: "image"},
+		{method: "POST", path: "/upload", headers: map[string]string{"Content-Type": "text/plain"}, status: http.StatusUnsupportedMediaType},
+		{method: "GET", path: "/report", body: "pdf"},
+		{method: "GET", path: "/report", headers: map[string]string{"Accept": "application/*"}, body: "pdf"},
+		{method: "GET", path: "/report", headers: map[string]string{"Accept": "text/html"}, status: http.StatusNotAcceptable},
+		{method: "GET", path: "/secure", status: http.StatusNotFound},
+		{method: "DELETE", path: "https://example.com/secure", body: "secure"},
+		{method: "GET", path: "/token", status: http.StatusNotFound},
+		{method: "GET", path: "/token", headers: map[string]string{"Authorization": "Bearer x"}, body: "token"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		status := http.StatusOK
+		if test.status != 0 {
+			status = test.status
+		}
+		if w.Code != status {
+			t.Errorf("%s %s: expected status %d but got %d", test.method, test.path, status, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body '%s' but got '%s'", test.method, test.path, test.body, w.Body.String())
+		}
+	}
+
+	// A handler with predicates does not replace the handler without predicates registered before it
+	l.Get("/download", fakeHandlerWithBody("json"))
+	l.Get("/download", fakeHandlerWithBody("csv")).Queries("format", "csv")
+	// nor the other way around
+	l.Get("/archive", fakeHandlerWithBody("csv")).Queries("format", "csv")
+	l.Get("/archive", fakeHandlerWithBody("json"))
+	// A handler without predicates replaces the previous one
+	l.Get("/replaced", fakeHandlerWithBody("old"))
+	l.Get("/replaced", fakeHandlerWithBody("new"))
+
+	test := htest.New(t, l)
+	for _, path := range []string{"/download", "/archive"} {
+		test.Get(path).Do().ExpectStatus(http.StatusOK).ExpectBody("json")
+		test.Get(path + "?format=csv").Do().ExpectStatus(http.StatusOK).ExpectBody("csv")
+	}
+	test.Get("/replaced").Do().ExpectBody("new")
+
+	recv := catchPanic(func() {
+		l.Get("/odd", fakeHandler()).Headers("X-Api-Version")
+	})
+	if recv == nil {
+		t.Error("Headers should panic with an odd number of arguments")
+	}
+}
+
+func fakeHandlerWithBody(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -24,7 +25,65 @@ const (
 	PATCH   = "PATCH"
 )
 
-var allowedHTTPMethods = [...]string{GET, HEAD, POST, PUT, DELETE, TRACE, OPTIONS, CONNECT, PATCH}
+var (
+	allowedHTTPMethods = []string{GET, HEAD, POST, PUT, DELETE, TRACE, OPTIONS, CONNECT, PATCH}
+	methodsMu          sync.RWMutex
+)
+
+// RegisterMethod allows routes to be registered for non standard HTTP methods such as WebDAV's PROPFIND, MKCOL, LOCK or the QUERY method.
+// Methods are case sensitive and must be valid HTTP tokens. Registering an already known method is a no-op.
+//
+// It should be called before registering routes using these methods, typically in an init function:
+// 	func init() {
+// 		lion.RegisterMethod("PROPFIND", "MKCOL")
+// 	}
+// 	...
+// 	l.Handle("PROPFIND", "/files/*path", propfindHandler)
+func RegisterMethod(methods ...string) {
+	methodsMu.Lock()
+	defer methodsMu.Unlock()
+
+	for _, method := range methods {
+		if !isValidMethod(method) {
+			panicl("invalid http method name '%s'", method)
+		}
+		if !isInStringSlice(allowedHTTPMethods, method) {
+			allowedHTTPMethods = append(allowedHTTPMethods, method)
+		}
+	}
+}
+
+// httpMethods returns the list of standard and registered HTTP methods
+func httpMethods() []string {
+	methodsMu.RLock()
+	methods := make([]string, len(allowedHTTPMethods))
+	copy(methods, allowedHTTPMethods)
+	methodsMu.RUnlock()
+	return methods
+}
+
+func isRegisteredMethod(method string) bool {
+	methodsMu.RLock()
+	defer methodsMu.RUnlock()
+	return isInStringSlice(allowedHTTPMethods, method)
+}
+
+// isValidMethod checks that method is a token as defined in RFC 7230 section 3.2.6
+func isValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for i := 0; i < len(method); i++ {
+		c := method[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
 
 // Router is the main component of Lion. It is responsible for registering handlers and middlewares
 type Router struct {
@@ -39,8 +98,33 @@ type Router struct {
 	host   string
 	hostrm *hostMatcher
 
-	notFoundHandler http.Handler
-	pool            sync.Pool
+	notFoundHandler         http.Handler
+	methodNotAllowedHandler http.Handler
+	automaticOptions        *bool
+	errorHandler            func(Context, error)
+	problemDetails          *bool
+	renderers               []Renderer
+	version                 string
+	versioning              *VersioningConfig
+	deprecation             *Deprecation
+	deprecationHook         func(Route, *http.Request)
+	bindConfig              *BindConfig
+	trailingSlash           *TrailingSlashPolicy
+	cleanPath               *CleanPathPolicy
+	pathCase                *PathCasePolicy
+	foldCase                bool // set on the root if a router has a PathCasePolicy other than CaseSensitive
+	encodedPath             *bool
+	debugHeader             *string
+	traceRequests           bool          // set on the root if a router has a debug header
+	registration            *[]RouteIssue // set by TryModule to skip invalid routes and collect their issues
+	pool                    sync.Pool
+
+	serverOpts      []ServerOption
+	server          *http.Server
+	serverDone      chan struct{} // closed once the server has been shut down and the hooks have run
+	serverMu        sync.Mutex
+	shutdownHooks   []func()
+	shutdownTimeout time.Duration
 }
 
 // New creates a new router instance
@@ -71,6 +155,7 @@ func (r *Router) Subrouter(mws ...Middleware) *Router {
 		pool:             newCtxPool(),
 		routes:           []*route{},
 		subrouters:       []*Router{},
+		registration:     r.registration,
 	}
 	nr.Use(mws...)
 	r.subrouters = append(r.subrouters, nr)
@@ -91,29 +176,53 @@ func (r *Router) Group(pattern string, mws ...Middleware) *Router {
 }
 
 // Handle is the underling method responsible for registering a handler for a specific method and pattern.
+// The method should either be a standard HTTP method or a method added using RegisterMethod.
+//
+// The name of a param such as :id or a wildcard such as *path is made of letters, digits and underscores,
+// so that it can be followed by a literal in the same segment:
+// 	l.Get("/img/:w-x-:h.png", imageHandler)
+// Names containing other characters must be enclosed in braces such as /users/:{user-id}.
 func (r *Router) Handle(method, pattern string, handler http.Handler) Route {
-	var p string
-	if !r.isRoot() && pattern == "/" && r.pattern != "" {
-		p = r.pattern
-	} else {
-		p = r.pattern + pattern
-	}
+	return r.handle(method, pattern, handler, r)
+}
 
-	built := r.buildMiddlewares(handler)
+// handle registers handler for method and pattern.
+// The handler is built with the middlewares of builder which can be nil to register handler as is.
+func (r *Router) handle(method, pattern string, handler http.Handler, builder *Router) *route {
+	p := r.routePattern(pattern)
+	if r.registration != nil {
+		if invalid := invalidIssues(r.Check(method, pattern)); len(invalid) > 0 {
+			r.collect(invalid...)
+			return &route{host: r.host, pattern: p, router: r, pathMatcher: skippedMatcher{}}
+		}
+	}
 
 	rm := r.root().hostrm.Register(r.host)
-	rt := rm.Register(method, p, built)
+	rt := rm.Register(method, p, handler)
 
 	// If this route does not exist in this Router instance then add it
 	if _, ok := r.findRoute(rt); !ok {
 		rt.pattern = p
 		rt.host = r.host
 		rt.pathMatcher = rm
+		if rt.router == nil {
+			rt.router = r
+		}
 		r.routes = append(r.routes, rt)
 	}
+
+	rt.latest = []*routeHandler{rt.register(builder, method, handler)}
 	return rt
 }
 
+// routePattern returns the pattern of a route registered on this router with pattern
+func (r *Router) routePattern(pattern string) string {
+	if !r.isRoot() && pattern == "/" && r.pattern != "" {
+		return r.pattern
+	}
+	return r.pattern + pattern
+}
+
 // ServeHTTP finds the handler associated with the request's path.
 // If it is not found it calls the NotFound handler
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -121,16 +230,33 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx.parent = req.Context()
 	ctx.ResponseWriter = w
 	ctx.req = req
+	ctx.router = r
 
-	if h := r.root().hostrm.Match(ctx, req); h != nil {
-		// We set the context only if there is a match
-		req = setParamContext(req, ctx)
+	tracing := r.root().traceRequests
+	if tracing {
+		ctx.startTrace(req)
+	}
 
-		h.ServeHTTP(w, req)
-	} else {
-		r.notFound(w, req) // r.middlewares.BuildHandler(HandlerFunc(r.NotFound)).ServeHTTPC
+	h := r.root().hostrm.Match(ctx, req)
+	if tracing {
+		ctx.endTrace(h)
+		// The router of the matched route decides whether the header is set
+		if name := ctx.router.debugHeaderName(); name != "" {
+			w.Header().Set(name, ctx.trace.Summary())
+		}
 	}
 
+	if h == nil {
+		// The request is not under the pattern and host of a group with its own not found settings
+		ctx.router = r.root()
+		h = http.HandlerFunc(http.NotFound)
+		if !ctx.router.hasNotFoundScope() {
+			h = ctx.router.notFoundScope()
+		}
+	}
+	req = setParamContext(req, ctx)
+	h.ServeHTTP(w, req)
+
 	ctx.Reset()
 	r.pool.Put(ctx)
 }
@@ -190,9 +316,19 @@ func (r *Router) Host(hostpattern string) *Router {
 }
 
 // Any registers the provided Handler for all of the allowed http methods: GET, HEAD, POST, PUT, DELETE, TRACE, OPTIONS, CONNECT, PATCH
+// and the methods added using RegisterMethod before calling Any.
 func (r *Router) Any(pattern string, handler http.Handler) Route {
-	rt := r.Handle(allowedHTTPMethods[0], pattern, handler).(*route)
-	rt.withMethods(r.middlewares.BuildHandler(handler), allowedHTTPMethods[1:]...)
+	var (
+		rt     Route
+		latest []*routeHandler
+	)
+	for _, method := range httpMethods() {
+		rt = r.Handle(method, pattern, handler)
+		latest = append(latest, rt.(*route).latest...)
+	}
+	if rt != nil {
+		rt.(*route).latest = latest
+	}
 	return rt
 }
 
@@ -242,10 +378,9 @@ func (r *Router) Patch(pattern string, handler http.Handler) Route {
 }
 
 // ANY registers the provided contextual Handler for all of the allowed http methods: GET, HEAD, POST, PUT, DELETE, TRACE, OPTIONS, CONNECT, PATCH
+// and the methods added using RegisterMethod before calling ANY.
 func (r *Router) ANY(pattern string, handler func(Context)) Route {
-	rt := r.Handle(allowedHTTPMethods[0], pattern, wrap(handler)).(*route)
-	rt.withMethods(r.middlewares.BuildHandler(wrap(handler)), allowedHTTPMethods[1:]...)
-	return rt
+	return r.Any(pattern, wrap(handler))
 }
 
 // GET registers an http GET method receiver with the provided contextual Handler
@@ -293,7 +428,65 @@ func (r *Router) PATCH(pattern string, handler func(Context)) Route {
 	return r.Handle("PATCH", pattern, wrap(handler))
 }
 
+// HandleE registers an error-returning contextual Handler for a specific method and pattern.
+// Errors returned by the handler, and panics occurring in it, are passed to the router's ErrorHandler.
+func (r *Router) HandleE(method, pattern string, handler func(Context) error) Route {
+	return r.Handle(method, pattern, wrapE(handler))
+}
+
+// ANYE registers the provided error-returning contextual Handler for all of the allowed http methods
+// and the methods added using RegisterMethod before calling ANYE.
+func (r *Router) ANYE(pattern string, handler func(Context) error) Route {
+	return r.Any(pattern, wrapE(handler))
+}
+
+// GETE registers an http GET method receiver with the provided error-returning contextual Handler
+func (r *Router) GETE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("GET", pattern, handler)
+}
+
+// HEADE registers an http HEAD method receiver with the provided error-returning contextual Handler
+func (r *Router) HEADE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("HEAD", pattern, handler)
+}
+
+// POSTE registers an http POST method receiver with the provided error-returning contextual Handler
+func (r *Router) POSTE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("POST", pattern, handler)
+}
+
+// PUTE registers an http PUT method receiver with the provided error-returning contextual Handler
+func (r *Router) PUTE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("PUT", pattern, handler)
+}
+
+// DELETEE registers an http DELETE method receiver with the provided error-returning contextual Handler
+func (r *Router) DELETEE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("DELETE", pattern, handler)
+}
+
+// TRACEE registers an http TRACE method receiver with the provided error-returning contextual Handler
+func (r *Router) TRACEE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("TRACE", pattern, handler)
+}
+
+// OPTIONSE registers an http OPTIONS method receiver with the provided error-returning contextual Handler
+func (r *Router) OPTIONSE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("OPTIONS", pattern, handler)
+}
+
+// CONNECTE registers an http CONNECT method receiver with the provided error-returning contextual Handler
+func (r *Router) CONNECTE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("CONNECT", pattern, handler)
+}
+
+// PATCHE registers an http PATCH method receiver with the provided error-returning contextual Handler
+func (r *Router) PATCHE(pattern string, handler func(Context) error) Route {
+	return r.HandleE("PATCH", pattern, handler)
+}
+
 // AnyFunc registers the provided HandlerFunc for all of the allowed http methods: GET, HEAD, POST, PUT, DELETE, TRACE, OPTIONS, CONNECT, PATCH
+// and the methods added using RegisterMethod before calling AnyFunc.
 func (r *Router) AnyFunc(pattern string, handler http.HandlerFunc) Route {
 	return r.Any(pattern, http.HandlerFunc(handler))
 }
@@ -396,6 +589,17 @@ func (r *Router) root() *Router {
 	return r.parent.root()
 }
 
+// nearest returns the first router for which set returns true, starting with r and walking up its parents.
+// It returns nil if there is none. It is used to find the setting inherited by a router.
+func (r *Router) nearest(set func(*Router) bool) *Router {
+	for rr := r; rr != nil; rr = rr.parent {
+		if set(rr) {
+			return rr
+		}
+	}
+	return nil
+}
+
 func (r *Router) findRoute(rt *route) (*route, bool) {
 	for _, route := range r.routes {
 		if route == rt {
@@ -423,18 +627,125 @@ func (r *Router) HandleFunc(method, pattern string, fn http.HandlerFunc) Route {
 	return r.Handle(method, pattern, http.HandlerFunc(fn))
 }
 
-// NotFound calls NotFoundHandler() if it is set. Otherwise, it calls net/http.NotFound
+// notFound answers a request matching no route under the pattern of r
 func (r *Router) notFound(w http.ResponseWriter, req *http.Request) {
-	if r.root().notFoundHandler != nil {
-		r.root().notFoundHandler.ServeHTTP(w, req)
-	} else {
-		http.NotFound(w, req)
+	r.notFoundScope().ServeHTTP(w, req)
+}
+
+// notFoundScope returns the handler of the requests matching no route under the pattern of r.
+// It is the NotFound handler of r or of its nearest parent defining one, unless problem details are enabled
+// on a router closer to r in which case the request is answered with an ErrorNotFound problem.
+func (r *Router) notFoundScope() http.Handler {
+	rr := r.nearest(func(rr *Router) bool { return rr.notFoundHandler != nil || rr.problemDetails != nil })
+	if rr != nil && rr.notFoundHandler == nil && !*rr.problemDetails {
+		rr = rr.parent.nearest(func(rr *Router) bool { return rr.notFoundHandler != nil })
+	}
+
+	switch {
+	case rr == nil:
+		return http.HandlerFunc(http.NotFound)
+	case rr.notFoundHandler != nil:
+		return rr.notFoundHandler
+	}
+	return problemNotFoundHandler
+}
+
+var problemNotFoundHandler = wrap(func(c Context) {
+	c.Error(ErrorNotFound)
+})
+
+// hasNotFoundScope reports whether the not found settings of r only apply under its pattern and host
+func (r *Router) hasNotFoundScope() bool {
+	return !r.isRoot() || r.host != "" || r.pattern != ""
+}
+
+// registerNotFoundScope makes the not found settings of r apply to the unmatched requests under its pattern and host
+func (r *Router) registerNotFoundScope() {
+	if !r.hasNotFoundScope() {
+		return
 	}
+	rm := r.root().hostrm.Register(r.host)
+	rm.RegisterNotFound(r.pattern, r)
 }
 
-// NotFoundHandler gives the ability to use a specific 404 NOT FOUND handler
+// NotFoundHandler gives the ability to use a specific 404 NOT FOUND handler.
+//
+// When used on a group, a subrouter or a router with a host, the handler applies to unmatched requests
+// under its pattern and host. It is built with the router's middlewares.
+// If several groups match, the one with the longest pattern is used.
+// 	api := l.Group("/api")
+// 	api.NotFoundHandler(jsonNotFound) // used for /api, /api/unknown, ...
+// 	l.NotFoundHandler(htmlNotFound)   // used for any other path
 func (r *Router) NotFoundHandler(handler http.Handler) {
-	r.notFoundHandler = handler
+	if !r.hasNotFoundScope() {
+		r.notFoundHandler = handler
+		return
+	}
+
+	r.notFoundHandler = r.buildMiddlewares(handler)
+	r.registerNotFoundScope()
+}
+
+// MethodNotAllowedHandler gives the ability to use a specific 405 METHOD NOT ALLOWED handler.
+// It applies to the routes registered on this router and its subrouters, unless they define their own.
+// The Allow header is set before calling the handler.
+func (r *Router) MethodNotAllowedHandler(handler http.Handler) {
+	r.methodNotAllowedHandler = handler
+}
+
+// methodNotAllowed returns the closest MethodNotAllowed handler walking up the router's parents
+func (r *Router) methodNotAllowed() http.Handler {
+	if rr := r.nearest(func(rr *Router) bool { return rr.methodNotAllowedHandler != nil }); rr != nil {
+		return rr.methodNotAllowedHandler
+	}
+	return defaultMethodNotAllowedHandler
+}
+
+// ErrorHandler sets the function that converts errors returned by error-returning contextual handlers
+// (registered with GETE, POSTE, HandleE, ...) into responses. Panics occurring in those handlers are recovered
+// and passed to it as a *PanicError.
+// It applies to this router and its subrouters, groups, resources and modules, unless they define their own.
+//
+// By default, HTTPErrors are rendered with Context.Error while panics and other errors result in a 500 Internal Server Error.
+// 	api.ErrorHandler(func(c lion.Context, err error) {
+// 		log.Println(err)
+// 		c.Error(err)
+// 	})
+func (r *Router) ErrorHandler(handler func(Context, error)) {
+	r.errorHandler = handler
+}
+
+// handleError returns the closest ErrorHandler walking up the router's parents
+func (r *Router) handleError() func(Context, error) {
+	if rr := r.nearest(func(rr *Router) bool { return rr.errorHandler != nil }); rr != nil {
+		return rr.errorHandler
+	}
+	return defaultErrorHandler
+}
+
+func defaultErrorHandler(c Context, err error) {
+	switch err.(type) {
+	case *PanicError:
+		c.Error(ErrorInternalServer)
+	case HTTPError:
+		c.Error(err)
+	default:
+		c.Error(ErrorInternalServer)
+	}
+}
+
+// AutomaticOptions enables or disables automatic responses to OPTIONS requests for the routes registered on this router and its subrouters.
+// It is enabled by default: an OPTIONS request to a route without an OPTIONS handler returns a 200 OK response with the Allow header set.
+// When disabled, such requests are handled as any other method not allowed.
+func (r *Router) AutomaticOptions(enabled bool) {
+	r.automaticOptions = &enabled
+}
+
+func (r *Router) automaticOptionsEnabled() bool {
+	if rr := r.nearest(func(rr *Router) bool { return rr.automaticOptions != nil }); rr != nil {
+		return *rr.automaticOptions
+	}
+	return true
 }
 
 // ServeFiles serves files located in root http.FileSystem
@@ -476,38 +787,6 @@ var (
 	lionLogger = log.New(os.Stdout, lionColor("[lion]")+" ", log.Ldate|log.Ltime)
 )
 
-// Run calls http.ListenAndServe for the current router.
-// If no addresses are specified as arguments, it will use the PORT environnement variable if it is defined. Otherwise, it will listen on port 3000 of the localmachine
-//
-// 	r := New()
-// 	r.Run() // will call
-// 	r.Run(":8080")
-func (r *Router) Run(addr ...string) {
-	var a string
-
-	if len(addr) == 0 {
-		if p := os.Getenv("PORT"); p != "" {
-			a = ":" + p
-		} else {
-			a = ":3000"
-		}
-	} else {
-		a = addr[0]
-	}
-
-	lionLogger.Printf("listening on %s", a)
-	lionLogger.Fatal(http.ListenAndServe(a, r))
-}
-
-// RunTLS calls http.ListenAndServeTLS for the current router
-//
-// 	r := New()
-// 	r.RunTLS(":3443", "cert.pem", "key.pem")
-func (r *Router) RunTLS(addr, certFile, keyFile string) {
-	lionLogger.Printf("listening on %s", addr)
-	lionLogger.Fatal(http.ListenAndServeTLS(addr, certFile, keyFile, r))
-}
-
 // Define registers some middleware using a name for reuse later using UseNamed method.
 func (r *Router) Define(name string, mws ...Middleware) {
 	r.namedMiddlewares[name] = append(r.namedMiddlewares[name], mws...)
@@ -523,13 +802,23 @@ func (r *Router) DefineFunc(name string, mws ...MiddlewareFunc) {
 // UseNamed adds a middleware already defined using Define method.
 // If it cannot find it in the current router, it will look for it in the parent router.
 func (r *Router) UseNamed(name string) {
-	if r.hasNamed(name) { // Find if it this is registered in the current router
-		r.Use(r.namedMiddlewares[name]...)
-	} else if !r.isRoot() { // Otherwise, look for it in parent router.
-		r.parent.UseNamed(name)
-	} else { // not found
-		panic("Unknow named middlewares: " + name)
+	mws, ok := r.namedMiddleware(name)
+	if !ok {
+		msg := "Unknow named middlewares: " + name
+		if !r.collect(RouteIssue{Kind: IssueInvalid, Host: r.host, Pattern: r.pattern, Message: msg}) {
+			panic(msg)
+		}
+		return
 	}
+	r.Use(mws...)
+}
+
+// namedMiddleware returns the middlewares defined with name in this router or the nearest parent defining it
+func (r *Router) namedMiddleware(name string) (Middlewares, bool) {
+	if rr := r.nearest(func(rr *Router) bool { return rr.hasNamed(name) }); rr != nil {
+		return rr.namedMiddlewares[name], true
+	}
+	return nil, false
 }
 
 func (r *Router) hasNamed(name string) bool {
@@ -3,12 +3,15 @@ package lion
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/celrenheit/htest"
 	"github.com/fatih/color"
@@ -524,14 +527,15 @@ func TestAutomaticOptions(t *testing.T) {
 	test := htest.New(t, l)
 	test.Options("/api").Do().
 		ExpectStatus(http.StatusOK).
-		ExpectHeader("Accept", "POST,PUT,TRACE,PATCH,OPTIONS")
+		ExpectHeader("Allow", "POST, PUT, TRACE, PATCH, OPTIONS")
 
 	test.Get("/api").Do().
-		ExpectStatus(http.StatusMethodNotAllowed)
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Allow", "POST, PUT, TRACE, PATCH, OPTIONS")
 
 	test.Options("/404").Do().
 		ExpectStatus(http.StatusNotFound).
-		ExpectHeader("Accept", "")
+		ExpectHeader("Allow", "")
 
 	// Allow custom options handler
 	l.Options("/api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -543,6 +547,92 @@ func TestAutomaticOptions(t *testing.T) {
 		ExpectHeader("Batman", "Robin")
 }
 
+func TestMethodNotAllowedHandler(t *testing.T) {
+	l := New()
+	l.MethodNotAllowedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("root"))
+	}))
+	l.Get("/web", fakeHandler())
+
+	api := l.Group("/api")
+	api.MethodNotAllowedHandler(wrap(func(c Context) {
+		c.WithStatus(http.StatusMethodNotAllowed).JSON(mss{"error": "method not allowed"})
+	}))
+	api.Get("/users", fakeHandler())
+	api.Post("/users", fakeHandler())
+	api.Subrouter().Get("/sub", fakeHandler())
+
+	noopts := l.Group("/noopts")
+	noopts.AutomaticOptions(false)
+	noopts.Get("/", fakeHandler())
+
+	test := htest.New(t, l)
+	test.Post("/web").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Allow", "GET, OPTIONS").
+		ExpectBody("root")
+
+	test.Delete("/api/users").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Allow", "GET, POST, OPTIONS").
+		ExpectHeader("Content-Type", contentTypeJSON).
+		ExpectBody(`{"error":"method not allowed"}`)
+
+	test.Delete("/api/sub").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectBody(`{"error":"method not allowed"}`)
+
+	test.Options("/noopts").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Allow", "GET").
+		ExpectBody("root")
+}
+
+func TestGroupNotFoundHandler(t *testing.T) {
+	l := New()
+	l.NotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("html"))
+	}))
+	l.Get("/", fakeHandler())
+
+	api := l.Group("/api", fakeMW("Api", "true"))
+	api.Get("/users", fakeHandler())
+	api.NotFoundHandler(wrap(func(c Context) {
+		c.WithStatus(http.StatusNotFound).JSON(mss{"error": "not found"})
+	}))
+
+	users := api.Group("/users/:id")
+	users.Get("/posts", fakeHandler())
+	users.NotFoundHandler(wrap(func(c Context) {
+		c.WithStatus(http.StatusNotFound).String("user %s", c.Param("id"))
+	}))
+
+	test := htest.New(t, l)
+	tests := []struct {
+		path, body, api string
+	}{
+		{"/unknown", "html", ""},
+		{"/apix", "html", ""},
+		{"/api", `{"error":"not found"}`, "true"},
+		{"/api/", `{"error":"not found"}`, "true"},
+		{"/api/unknown/path", `{"error":"not found"}`, "true"},
+		{"/api/users/42/unknown", "user 42", "true"},
+		{"/api/users/42", "user 42", "true"},
+	}
+
+	for _, tt := range tests {
+		test.Get(tt.path).Do().
+			ExpectStatus(http.StatusNotFound).
+			ExpectHeader("Api", tt.api).
+			ExpectBody(tt.body)
+	}
+
+	test.Get("/api/users").Do().
+		ExpectStatus(http.StatusOK)
+}
+
 func TestValidation(t *testing.T) {
 	l := New()
 	l.Get("/api/:key", fakeHandler())
@@ -597,6 +687,187 @@ func TestValidation(t *testing.T) {
 	}
 }
 
+func TestOptionalParams(t *testing.T) {
+	l := New()
+	l.GetFunc("/posts/:year/:month?/:day?", func(w http.ResponseWriter, r *http.Request) {
+		c := C(r)
+		month, _ := c.ParamOk("month")
+		day, _ := c.ParamOk("day")
+		fmt.Fprintf(w, "%s-%s-%s", c.Param("year"), month, day)
+	})
+	l.GetFunc("/files/:file.:ext?", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s", Param(r, "file"), Param(r, "ext"))
+	})
+	l.GetFunc("/n/:n([0-9]+)?", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "n=%s", Param(r, "n"))
+	})
+	l.GetFunc("/docs/*path?", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "path=%s", Param(r, "path"))
+	})
+	l.PostFunc("/posts/:year/:month?/:day?", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		path, body string
+	}{
+		{"/posts/2016", "2016--"},
+		{"/posts/2016/11", "2016-11-"},
+		{"/posts/2016/11/30", "2016-11-30"},
+		{"/files/readme", "readme|"},
+		{"/files/readme.md", "readme|md"},
+		{"/n", "n="},
+		{"/n/42", "n=42"},
+		{"/docs", "path="},
+		{"/docs/a/b", "path=a/b"},
+	}
+
+	test := htest.New(t, l)
+	for _, tt := range tests {
+		test.Get(tt.path).Do().
+			ExpectStatus(http.StatusOK).
+			ExpectBody(tt.body)
+	}
+
+	test.Post("/posts/2016/11").Do().
+		ExpectStatus(http.StatusCreated)
+	test.Get("/posts").Do().
+		ExpectStatus(http.StatusNotFound)
+	test.Get("/n/abc").Do().
+		ExpectStatus(http.StatusNotFound)
+
+	if got := len(l.Routes()); got != 4 {
+		t.Errorf("Optional params should not create additional routes: got %d routes", got)
+	}
+
+	recv := catchPanic(func() {
+		l.Get("/optional/:a?/static", fakeHandler())
+	})
+	if recv == nil {
+		t.Error("Should panic for an optional parameter not at the end of the pattern")
+	}
+
+	recv = catchPanic(func() {
+		l.Get("/dup/:a/:a?", fakeHandler())
+	})
+	if recv == nil {
+		t.Error("Should panic for duplicated optional parameter names")
+	}
+}
+
+func TestBacktrackingBound(t *testing.T) {
+	l := New()
+	l.Get("/p/:a-:b-:c-:d/z", fakeHandler())
+	l.Get("/r/*a/x/*b/x/*c/y", fakeHandler())
+
+	paths := []string{
+		"/p/" + strings.Repeat("-", 2000) + "/y",
+		"/r/" + strings.Repeat("x/", 2000) + "z",
+	}
+
+	test := htest.New(t, l)
+	for _, path := range paths {
+		start := time.Now()
+		test.Get(path).Do().ExpectStatus(http.StatusNotFound)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("matching a path of %d bytes took %s", len(path), elapsed)
+		}
+	}
+
+	test.Get("/p/a-b-c-d/z").Do().ExpectStatus(http.StatusOK)
+	test.Get("/r/a/x/b/x/c/x/y").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestComplexPatterns(t *testing.T) {
+	l := New()
+	l.GetFunc("/repos/*path/blob/:ref", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "blob %s@%s", Param(r, "path"), Param(r, "ref"))
+	}).WithName("blob")
+	l.GetFunc("/repos/*path", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "repo %s", Param(r, "path"))
+	})
+	l.GetFunc("/compare/:from...:to", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "compare %s %s", Param(r, "from"), Param(r, "to"))
+	}).WithName("compare")
+	l.GetFunc("/img/:w-x-:h.png", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "img %sx%s", Param(r, "w"), Param(r, "h"))
+	}).WithName("img")
+	l.GetFunc("/size/:w|int-:unit", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "size %s %s", Param(r, "w"), Param(r, "unit"))
+	})
+
+	tests := []struct {
+		path, body string
+	}{
+		{"/repos/a/b/blob/main", "blob a/b@main"},
+		{"/repos/a/blob/b/blob/main", "blob a/blob/b@main"},
+		{"/repos/a/b", "repo a/b"},
+		{"/repos/a/blob/main/docs", "repo a/blob/main/docs"},
+		{"/compare/v1.0...v2.0", "compare v1.0 v2.0"},
+		{"/compare/main...feature", "compare main feature"},
+		{"/img/100-x-200.png", "img 100x200"},
+		{"/img/a-b-x-c.png", "img a-bxc"},
+		{"/size/42-px", "size 42 px"},
+	}
+
+	test := htest.New(t, l)
+	for _, tt := range tests {
+		test.Get(tt.path).Do().
+			ExpectStatus(http.StatusOK).
+			ExpectBody(tt.body)
+	}
+
+	test.Get("/compare/v1.0").Do().
+		ExpectStatus(http.StatusNotFound)
+	test.Get("/img/100x200.png").Do().
+		ExpectStatus(http.StatusNotFound)
+	test.Get("/size/big-px").Do().
+		ExpectStatus(http.StatusNotFound)
+
+	// Names end at the first character which is not a letter, a digit or an underscore unless they are enclosed in braces
+	names := New()
+	names.GetFunc("/users/:{user-id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "user %s", Param(r, "user-id"))
+	})
+	names.GetFunc("/files/*{file-path}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "file %s", Param(r, "file-path"))
+	})
+	names.GetFunc("/archives/*path.zip", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "archive %s", Param(r, "path"))
+	})
+	names.GetFunc("/thumbs/:{w}px", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "thumb %s", Param(r, "w"))
+	})
+	nt := htest.New(t, names)
+	nt.Get("/users/42").Do().ExpectStatus(http.StatusOK).ExpectBody("user 42")
+	nt.Get("/users/42-id").Do().ExpectStatus(http.StatusOK).ExpectBody("user 42-id")
+	nt.Get("/files/a/b").Do().ExpectStatus(http.StatusOK).ExpectBody("file a/b")
+	nt.Get("/archives/a/b.zip").Do().ExpectStatus(http.StatusOK).ExpectBody("archive a/b")
+	nt.Get("/thumbs/64px").Do().ExpectStatus(http.StatusOK).ExpectBody("thumb 64")
+
+	for _, pattern := range []string{"/img/:{w-x", "/img/:{w:h}", "/repos/:a:b", "/repos/*path*rest"} {
+		if recv := catchPanic(func() { New().Get(pattern, fakeHandler()) }); recv == nil {
+			t.Errorf("Should panic for the invalid param name in %s", pattern)
+		}
+	}
+
+	paths := []struct {
+		name   string
+		params map[string]string
+		path   string
+	}{
+		{"blob", map[string]string{"path": "a/b", "ref": "main"}, "/repos/a/b/blob/main"},
+		{"compare", map[string]string{"from": "v1.0", "to": "v2.0"}, "/compare/v1.0...v2.0"},
+		{"img", map[string]string{"w": "100", "h": "200"}, "/img/100-x-200.png"},
+	}
+	for _, tt := range paths {
+		path, err := l.Route(tt.name).Path(tt.params)
+		if err != nil || path != tt.path {
+			t.Errorf("Route %s: expected path %s but got %s, %v", tt.name, tt.path, path, err)
+		}
+	}
+}
+
 func TestTrailingSlashRedirect(t *testing.T) {
 	router := New()
 	router.Get("/a", fakeHandler())
@@ -669,6 +940,65 @@ func TestUSEContext(t *testing.T) {
 		ExpectHeader("FOO", "BAR")
 }
 
+func TestRegisterMethod(t *testing.T) {
+	l := New()
+	recv := catchPanic(func() {
+		l.HandleFunc("PROPFIND", "/files", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	if recv == nil {
+		t.Error("Should panic for an unregistered http method")
+	}
+
+	// The registry is global, restore it so that the other tests only see the standard methods
+	methodsMu.RLock()
+	standard := append([]string{}, allowedHTTPMethods...)
+	methodsMu.RUnlock()
+	t.Cleanup(func() {
+		methodsMu.Lock()
+		allowedHTTPMethods = standard
+		methodsMu.Unlock()
+	})
+
+	RegisterMethod("PROPFIND", "MKCOL", "QUERY")
+
+	l.HandleFunc("PROPFIND", "/files/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, "PROPFIND::%s", Param(r, "path"))
+	}).WithMethod("MKCOL", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	l.AnyFunc("/any", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Any::%s", r.Method)
+	})
+
+	test := htest.New(t, l)
+	test.Request("PROPFIND", "/files/a/b").Do().
+		ExpectStatus(http.StatusMultiStatus).
+		ExpectBody("PROPFIND::a/b")
+	test.Request("MKCOL", "/files/a").Do().
+		ExpectStatus(http.StatusCreated)
+	test.Request("QUERY", "/files/a").Do().
+		ExpectStatus(http.StatusMethodNotAllowed)
+	test.Request("QUERY", "/any").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("Any::QUERY")
+	test.Options("/files/a").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Allow", "PROPFIND, MKCOL, OPTIONS")
+
+	methods := l.Routes().ByPattern("/files/*path").Methods()
+	if len(methods) != 2 || methods[0] != "PROPFIND" || methods[1] != "MKCOL" {
+		t.Errorf("Route methods should be [PROPFIND MKCOL] but got %v", methods)
+	}
+
+	recv = catchPanic(func() {
+		RegisterMethod("BAD METHOD")
+	})
+	if recv == nil {
+		t.Error("Should panic for an invalid method name")
+	}
+}
+
 func catchPanic(fn func()) (recv interface{}) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -712,3 +1042,54 @@ func randToken() string {
 var red = color.New(color.FgRed).SprintFunc()
 var green = color.New(color.FgGreen).SprintFunc()
 var cyan = color.New(color.FgCyan).SprintFunc()
+
+type errorResource struct{}
+
+func (errorResource) Get(c Context) error { return ErrorForbidden }
+
+func TestErrorHandler(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	l := New()
+	l.GETE("/ok", func(c Context) error {
+		return c.String("ok")
+	})
+	l.GETE("/http", func(c Context) error {
+		return ErrorUnauthorized
+	})
+	l.GETE("/plain", func(c Context) error {
+		return errBoom
+	})
+	l.GETE("/panic", func(c Context) error {
+		panic("oops")
+	})
+
+	api := l.Group("/api")
+	api.ErrorHandler(func(c Context, err error) {
+		if _, ok := err.(*PanicError); ok {
+			c.WithStatus(http.StatusServiceUnavailable).String("recovered")
+			return
+		}
+		c.WithStatus(http.StatusTeapot).String("api: %s", err.Error())
+	})
+	api.POSTE("/plain", func(c Context) error {
+		return errBoom
+	})
+	api.GETE("/panic", func(c Context) error {
+		panic("oops")
+	})
+	api.Group("/v1").PUTE("/nested", func(c Context) error {
+		return errBoom
+	})
+	api.Resource("/resource", errorResource{})
+
+	test := htest.New(t, l)
+	test.Get("/ok").Do().ExpectStatus(http.StatusOK).ExpectBody("ok")
+	test.Get("/http").Do().ExpectStatus(http.StatusUnauthorized).ExpectBody("Unauthorized")
+	test.Get("/plain").Do().ExpectStatus(http.StatusInternalServerError).ExpectBody("Internal Server Error")
+	test.Get("/panic").Do().ExpectStatus(http.StatusInternalServerError).ExpectBody("Internal Server Error")
+	test.Post("/api/plain").Do().ExpectStatus(http.StatusTeapot).ExpectBody("api: boom")
+	test.Get("/api/panic").Do().ExpectStatus(http.StatusServiceUnavailable).ExpectBody("recovered")
+	test.Put("/api/v1/nested").Do().ExpectStatus(http.StatusTeapot).ExpectBody("api: boom")
+	test.Get("/api/resource").Do().ExpectStatus(http.StatusTeapot).ExpectBody("api: Forbidden")
+}
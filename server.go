@@ -0,0 +1,322 @@
+package lion
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownTimeout is the time given to active requests to complete when a running server is asked to stop.
+const defaultShutdownTimeout = 30 * time.Second
+
+// ServerConfig holds the settings used to build the *http.Server behind Run, RunTLS, RunContext and RunTLSContext.
+// Each field maps to the http.Server field of the same name.
+type ServerConfig struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	TLSConfig         *tls.Config
+	ErrorLog          *log.Logger
+	ConnState         func(net.Conn, http.ConnState)
+}
+
+// DefaultServerConfig returns the configuration used when no ServerOption is provided.
+// ReadTimeout and WriteTimeout are left disabled so that large uploads and long running responses
+// (file downloads, streaming) are not cut off. Use WithReadTimeout and WithWriteTimeout to set them.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		ErrorLog:          lionLogger,
+	}
+}
+
+// ServerOption modifies a ServerConfig
+type ServerOption func(*ServerConfig)
+
+// WithServerConfig replaces the whole configuration with cfg
+func WithServerConfig(cfg ServerConfig) ServerOption {
+	return func(c *ServerConfig) {
+		*c = cfg
+	}
+}
+
+// WithAddr sets the TCP address the server listens on
+func WithAddr(addr string) ServerOption {
+	return func(c *ServerConfig) {
+		c.Addr = addr
+	}
+}
+
+// WithReadTimeout sets the maximum duration for reading the entire request, including the body
+func WithReadTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) {
+		c.ReadTimeout = d
+	}
+}
+
+// WithReadHeaderTimeout sets the amount of time allowed to read request headers
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) {
+		c.ReadHeaderTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the maximum duration before timing out writes of the response
+func WithWriteTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) {
+		c.WriteTimeout = d
+	}
+}
+
+// WithIdleTimeout sets the maximum amount of time to wait for the next request when keep-alives are enabled
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) {
+		c.IdleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum number of bytes the server will read parsing the request header's keys and values
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(c *ServerConfig) {
+		c.MaxHeaderBytes = n
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used by RunTLS and RunTLSContext.
+// If it contains certificates, the certFile and keyFile arguments of RunTLS can be left empty.
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(c *ServerConfig) {
+		c.TLSConfig = cfg
+	}
+}
+
+// WithErrorLog sets the logger used for errors accepting connections and unexpected behavior from handlers
+func WithErrorLog(logger *log.Logger) ServerOption {
+	return func(c *ServerConfig) {
+		c.ErrorLog = logger
+	}
+}
+
+// WithConnState sets a function called when a client connection changes state
+func WithConnState(fn func(net.Conn, http.ConnState)) ServerOption {
+	return func(c *ServerConfig) {
+		c.ConnState = fn
+	}
+}
+
+// ConfigureServer registers options applied to every server built by this router, including the ones started by Run and RunTLS.
+//
+// 	l := New()
+// 	l.ConfigureServer(lion.WithWriteTimeout(10*time.Second), lion.WithMaxHeaderBytes(1<<16))
+// 	l.Run(":8080")
+func (r *Router) ConfigureServer(opts ...ServerOption) {
+	r.serverOpts = append(r.serverOpts, opts...)
+}
+
+// Server builds a configured *http.Server serving this router.
+// It starts from DefaultServerConfig, applies the options registered with ConfigureServer and then the options passed as arguments.
+//
+// This is useful if you want to start the server yourself:
+// 	srv := l.Server(lion.WithAddr(":8080"))
+// 	srv.ListenAndServe()
+func (r *Router) Server(opts ...ServerOption) *http.Server {
+	cfg := DefaultServerConfig()
+	for _, opt := range r.serverOpts {
+		opt(&cfg)
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           r,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		TLSConfig:         cfg.TLSConfig,
+		ErrorLog:          cfg.ErrorLog,
+		ConnState:         cfg.ConnState,
+	}
+}
+
+// Run starts an http server for the current router built using Server.
+// If no addresses are specified as arguments, it will use the address set with ConfigureServer if any,
+// then the PORT environnement variable if it is defined. Otherwise, it will listen on port 3000 of the localmachine
+//
+// 	r := New()
+// 	r.Run() // will call
+// 	r.Run(":8080")
+//
+// Run exits the process if the server fails. Use RunContext to handle the returned error yourself.
+func (r *Router) Run(addr ...string) {
+	if err := r.RunContext(context.Background(), addr...); err != nil {
+		lionLogger.Fatal(err)
+	}
+}
+
+// RunTLS starts an https server for the current router built using Server.
+//
+// 	r := New()
+// 	r.RunTLS(":3443", "cert.pem", "key.pem")
+//
+// RunTLS exits the process if the server fails. Use RunTLSContext to handle the returned error yourself.
+func (r *Router) RunTLS(addr, certFile, keyFile string) {
+	if err := r.RunTLSContext(context.Background(), addr, certFile, keyFile); err != nil {
+		lionLogger.Fatal(err)
+	}
+}
+
+// RunContext starts an http server for the current router and blocks until it is stopped.
+// The address is resolved in the same way as Run.
+//
+// The server is gracefully shut down when ctx is done or when the process receives SIGINT or SIGTERM:
+// it stops accepting new connections, waits for active requests to complete (see ShutdownTimeout)
+// and runs the hooks registered with OnShutdown.
+//
+// 	ctx, cancel := context.WithCancel(context.Background())
+// 	defer cancel()
+// 	if err := r.RunContext(ctx, ":8080"); err != nil {
+// 		log.Fatal(err)
+// 	}
+func (r *Router) RunContext(ctx context.Context, addr ...string) error {
+	var opts []ServerOption
+	if len(addr) > 0 {
+		opts = append(opts, WithAddr(addr[0]))
+	}
+	srv := r.Server(opts...)
+	if srv.Addr == "" {
+		srv.Addr = resolveAddr()
+	}
+	return r.serve(ctx, srv, srv.ListenAndServe)
+}
+
+// RunTLSContext is the TLS counterpart of RunContext.
+// If addr is empty, the address set with ConfigureServer is used.
+func (r *Router) RunTLSContext(ctx context.Context, addr, certFile, keyFile string) error {
+	var opts []ServerOption
+	if addr != "" {
+		opts = append(opts, WithAddr(addr))
+	}
+	srv := r.Server(opts...)
+	return r.serve(ctx, srv, func() error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// Shutdown gracefully stops the server started by RunContext, RunTLSContext, Run or RunTLS.
+// It waits for active requests to complete until ctx is done and then runs the hooks registered with OnShutdown.
+// It returns nil if no server is running.
+func (r *Router) Shutdown(ctx context.Context) error {
+	r.serverMu.Lock()
+	srv, done := r.server, r.serverDone
+	r.server, r.serverDone = nil, nil
+	hooks := append([]func(){}, r.shutdownHooks...)
+	r.serverMu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	// Let the running server return once everything is done
+	defer close(done)
+
+	err := srv.Shutdown(ctx)
+	for _, fn := range hooks {
+		fn()
+	}
+	return err
+}
+
+// OnShutdown registers functions to be called, in order, once the server has been shut down.
+// This can be used to close database connections, flush logs, ...
+func (r *Router) OnShutdown(fns ...func()) {
+	r.serverMu.Lock()
+	r.shutdownHooks = append(r.shutdownHooks, fns...)
+	r.serverMu.Unlock()
+}
+
+// ShutdownTimeout sets the maximum duration to wait for active requests when the server is stopped by a signal or by the context passed to RunContext.
+// It defaults to 30 seconds.
+func (r *Router) ShutdownTimeout(d time.Duration) {
+	r.shutdownTimeout = d
+}
+
+func (r *Router) serve(ctx context.Context, srv *http.Server, listen func() error) error {
+	done := make(chan struct{})
+	r.serverMu.Lock()
+	r.server, r.serverDone = srv, done
+	r.serverMu.Unlock()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- listen()
+	}()
+
+	lionLogger.Printf("listening on %s", srv.Addr)
+
+	select {
+	case err := <-errc:
+		if err != http.ErrServerClosed {
+			// The server failed to start
+			r.serverMu.Lock()
+			if r.server == srv {
+				r.server, r.serverDone = nil, nil
+			}
+			r.serverMu.Unlock()
+			return err
+		}
+		// Shutdown has been called by someone else, wait for the active requests and the hooks
+		<-done
+		return nil
+	case <-ctx.Done():
+	case sig := <-sigs:
+		lionLogger.Printf("received %s, shutting down", sig)
+	}
+
+	timeout := r.shutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Shutdown may also have been called by someone else in the meantime
+	err := r.Shutdown(sctx)
+	<-done
+	if err != nil {
+		return err
+	}
+
+	if err := <-errc; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func resolveAddr(addr ...string) string {
+	if len(addr) > 0 {
+		return addr[0]
+	}
+
+	if p := os.Getenv("PORT"); p != "" {
+		return ":" + p
+	}
+	return ":3000"
+}
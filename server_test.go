@@ -0,0 +1,227 @@
+package lion
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunContextGracefulShutdown(t *testing.T) {
+	addr := freeAddr(t)
+
+	started := make(chan struct{})
+	l := New()
+	l.GetFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	var hooks []string
+	l.OnShutdown(func() { hooks = append(hooks, "first") }, func() { hooks = append(hooks, "second") })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.RunContext(ctx, addr)
+	}()
+	waitForServer(t, addr)
+
+	bodyc := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			bodyc <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		bodyc <- string(b)
+	}()
+
+	<-started
+	cancel()
+
+	if err := <-errc; err != nil {
+		t.Errorf("RunContext should not return an error but got: %s", err)
+	}
+
+	if body := <-bodyc; body != "done" {
+		t.Errorf("In-flight request should complete but got: %s", body)
+	}
+
+	if len(hooks) != 2 || hooks[0] != "first" || hooks[1] != "second" {
+		t.Errorf("Shutdown hooks should have been called in order but got: %v", hooks)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	addr := freeAddr(t)
+
+	l := New()
+	hookCalled := false
+	l.OnShutdown(func() { hookCalled = true })
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.RunContext(context.Background(), addr)
+	}()
+	waitForServer(t, addr)
+
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-errc; err != nil {
+		t.Errorf("RunContext should not return an error but got: %s", err)
+	}
+
+	if !hookCalled {
+		t.Error("Shutdown hook should have been called")
+	}
+
+	// No server running
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown without a running server should not error but got: %s", err)
+	}
+}
+
+func TestShutdownWaitsForHooks(t *testing.T) {
+	addr := freeAddr(t)
+
+	started := make(chan struct{})
+	l := New()
+	l.GetFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	hookc := make(chan struct{})
+	l.OnShutdown(func() {
+		time.Sleep(50 * time.Millisecond)
+		close(hookc)
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.RunContext(context.Background(), addr)
+	}()
+	waitForServer(t, addr)
+
+	go http.Get("http://" + addr + "/slow")
+	<-started
+	go l.Shutdown(context.Background())
+
+	if err := <-errc; err != nil {
+		t.Errorf("RunContext should not return an error but got: %s", err)
+	}
+	select {
+	case <-hookc:
+	default:
+		t.Error("RunContext should return once the shutdown hooks have run")
+	}
+}
+
+func TestRunContextConfiguredAddr(t *testing.T) {
+	addr := freeAddr(t)
+
+	l := New()
+	l.ConfigureServer(WithAddr(addr))
+	l.Get("/", fakeHandlerWithBody("configured"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.RunContext(ctx)
+	}()
+	waitForServer(t, addr)
+	cancel()
+
+	if err := <-errc; err != nil {
+		t.Errorf("RunContext should not return an error but got: %s", err)
+	}
+}
+
+func TestRunContextListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	l := New()
+	if err := l.RunContext(context.Background(), ln.Addr().String()); err == nil {
+		t.Error("RunContext should return an error when the address is already in use")
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func waitForServer(t *testing.T, addr string) {
+	for i := 0; i < 100; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server did not start on %s", addr)
+}
+
+func TestServerConfig(t *testing.T) {
+	l := New()
+
+	srv := l.Server()
+	def := DefaultServerConfig()
+	if srv.Handler != l {
+		t.Error("Server handler should be the router")
+	}
+	if srv.ReadTimeout != 0 || srv.WriteTimeout != 0 {
+		t.Errorf("Read and write timeouts should be disabled by default but got %s and %s", srv.ReadTimeout, srv.WriteTimeout)
+	}
+	if srv.ReadHeaderTimeout != def.ReadHeaderTimeout || srv.IdleTimeout != def.IdleTimeout || srv.MaxHeaderBytes != def.MaxHeaderBytes {
+		t.Errorf("Server should use the default configuration but got %+v", srv)
+	}
+
+	connState := func(net.Conn, http.ConnState) {}
+	l.ConfigureServer(
+		WithReadTimeout(time.Second),
+		WithWriteTimeout(2*time.Second),
+		WithMaxHeaderBytes(1024),
+		WithConnState(connState),
+	)
+
+	srv = l.Server(WithAddr(":1234"), WithWriteTimeout(3*time.Second))
+	if srv.Addr != ":1234" {
+		t.Errorf("Incorrect addr: got '%s' want '%s'", srv.Addr, ":1234")
+	}
+	if srv.ReadTimeout != time.Second {
+		t.Errorf("Incorrect read timeout: got %s want %s", srv.ReadTimeout, time.Second)
+	}
+	if srv.WriteTimeout != 3*time.Second {
+		t.Errorf("Options passed to Server should override ConfigureServer: got %s want %s", srv.WriteTimeout, 3*time.Second)
+	}
+	if srv.MaxHeaderBytes != 1024 {
+		t.Errorf("Incorrect max header bytes: got %d want %d", srv.MaxHeaderBytes, 1024)
+	}
+	if srv.ConnState == nil {
+		t.Error("ConnState should be set")
+	}
+
+	srv = l.Server(WithServerConfig(ServerConfig{Addr: ":80"}))
+	if srv.Addr != ":80" || srv.ReadTimeout != 0 || srv.ErrorLog != nil {
+		t.Errorf("WithServerConfig should replace the whole configuration but got %+v", srv)
+	}
+}
@@ -0,0 +1,160 @@
+package lion
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RegistrationError is returned by the Try functions when a registration would panic.
+// Nothing is registered when it is returned.
+type RegistrationError struct {
+	// Method is empty if the error is not about the registration of a route
+	Method  string
+	Pattern string
+	// Issues are the issues of kind IssueInvalid preventing the registration
+	Issues []RouteIssue
+}
+
+func (e *RegistrationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.Message
+		if issue.Pattern != e.Pattern {
+			msgs[i] = issue.Pattern + ": " + issue.Message
+		}
+	}
+	target := e.Pattern
+	if e.Method != "" {
+		target = e.Method + " " + target
+	}
+	if target != "" {
+		target = " " + target
+	}
+	return "lion: cannot register" + target + ": " + strings.Join(msgs, ", ")
+}
+
+func newRegistrationError(method, pattern, format string, args ...interface{}) *RegistrationError {
+	return &RegistrationError{
+		Method:  method,
+		Pattern: pattern,
+		Issues:  []RouteIssue{{Kind: IssueInvalid, Pattern: pattern, Message: fmt.Sprintf(format, args...)}},
+	}
+}
+
+// TryHandle is like Handle but it returns a *RegistrationError instead of panicking when the registration would fail,
+// for example if the method is not registered, the pattern is invalid or conflicts with a route already registered.
+// The registration is checked with Router.Check before changing anything.
+// It is useful when the routes come from a configuration or a third-party module:
+//
+//	if _, err := l.TryHandle(cfg.Method, cfg.Pattern, handler); err != nil {
+//		log.Printf("skipping route: %s", err)
+//	}
+func (r *Router) TryHandle(method, pattern string, handler http.Handler) (Route, error) {
+	if invalid := invalidIssues(r.Check(method, pattern)); len(invalid) > 0 {
+		return nil, &RegistrationError{Method: method, Pattern: r.routePattern(pattern), Issues: invalid}
+	}
+	return r.Handle(method, pattern, handler), nil
+}
+
+// TryGroup is like Group but it returns a *RegistrationError instead of panicking if the pattern is invalid
+func (r *Router) TryGroup(pattern string, mws ...Middleware) (*Router, error) {
+	p := r.pattern + pattern
+	if len(p) == 0 || p[0] != '/' {
+		return nil, newRegistrationError("", p, "path must start with '/' in path '%s'", p)
+	}
+	return r.Group(pattern, mws...), nil
+}
+
+// TryUseNamed is like UseNamed but it returns a *RegistrationError instead of panicking if the named middlewares are not defined
+func (r *Router) TryUseNamed(name string) error {
+	mws, ok := r.namedMiddleware(name)
+	if !ok {
+		return newRegistrationError("", r.pattern, "unknown named middlewares: %s", name)
+	}
+	r.Use(mws...)
+	return nil
+}
+
+// TryModule is like Module but it returns a *RegistrationError instead of panicking if a module cannot be registered.
+// The base pattern and the required middlewares of all the modules are checked before registering any of them.
+// The routes registered by the Routes method of a module are then checked one by one:
+// the invalid ones and the unknown named middlewares are skipped and their issues are returned
+// once all the modules are registered. The other routes are registered.
+func (r *Router) TryModule(modules ...Module) error {
+	for _, m := range modules {
+		if err := r.checkModule(m); err != nil {
+			return err
+		}
+	}
+
+	prev := r.registration
+	var issues []RouteIssue
+	r.registration = &issues
+	for _, m := range modules {
+		r.registerModule(m)
+	}
+	r.endRegistration(&issues, prev)
+
+	if len(issues) > 0 {
+		return &RegistrationError{Pattern: r.pattern, Issues: issues}
+	}
+	return nil
+}
+
+// checkModule returns an error if registerModule would panic before calling the Routes method of m
+func (r *Router) checkModule(m Module) error {
+	base := m.Base()
+	if invalid := invalidIssues(r.Check(GET, base)); len(invalid) > 0 {
+		return &RegistrationError{Pattern: r.routePattern(base), Issues: invalid}
+	}
+
+	if req, ok := m.(moduleRequirements); ok {
+		for _, dep := range req.Requires() {
+			if !r.hasNamed(dep) {
+				return newRegistrationError("", r.routePattern(base), "unmet middleware requirement for %s", dep)
+			}
+		}
+	}
+	return nil
+}
+
+// invalidIssues returns the issues of kind IssueInvalid
+func invalidIssues(issues []RouteIssue) (invalid []RouteIssue) {
+	for _, issue := range issues {
+		if issue.Kind == IssueInvalid {
+			invalid = append(invalid, issue)
+		}
+	}
+	return
+}
+
+// collect adds issues to the issues collected by TryModule and reports whether r is collecting them
+func (r *Router) collect(issues ...RouteIssue) bool {
+	if r.registration == nil {
+		return false
+	}
+	*r.registration = append(*r.registration, issues...)
+	return true
+}
+
+// endRegistration stops collecting issues in r and the subrouters created while collecting them
+func (r *Router) endRegistration(issues, prev *[]RouteIssue) {
+	if r.registration != issues {
+		return
+	}
+	r.registration = prev
+	for _, sr := range r.subrouters {
+		sr.endRegistration(issues, prev)
+	}
+}
+
+// skippedMatcher is the matcher of the routes skipped by TryModule, nothing is registered in it
+type skippedMatcher struct{}
+
+func (skippedMatcher) Register(method, pattern string, handler http.Handler) *route { return nil }
+func (skippedMatcher) RegisterNotFound(pattern string, router *Router)              {}
+func (skippedMatcher) Match(c *ctx, req *http.Request) (*ctx, http.Handler)         { return c, nil }
+func (skippedMatcher) Path(pattern string, params map[string]interface{}) (string, error) {
+	return "", fmt.Errorf("lion: the route %s has not been registered", pattern)
+}
@@ -0,0 +1,176 @@
+package lion
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/celrenheit/htest"
+)
+
+func TestTryHandle(t *testing.T) {
+	l := New()
+	if _, err := l.TryHandle(GET, "/users/:id", fakeHandlerWithBody("user")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		method, pattern, err string
+	}{
+		{GET, "users", "path must begin with '/'"},
+		{"FETCH", "/users", "invalid http method"},
+		{GET, "/users/:name", "Conflicting parameter name"},
+		{GET, "/posts/:id|unknown", "unknown parameter type"},
+	}
+	for _, test := range tests {
+		rt, err := l.TryHandle(test.method, test.pattern, fakeHandler())
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s %s: expected an error containing %q but got %v", test.method, test.pattern, test.err, err)
+		}
+		if rt != nil {
+			t.Errorf("%s %s: no route should be returned", test.method, test.pattern)
+		}
+	}
+
+	// Nothing is registered when the pattern is invalid
+	_, err := l.TryHandle(GET, "/a/:id/b/:id", fakeHandler())
+	if rerr, ok := err.(*RegistrationError); !ok || len(rerr.Issues) != 1 || rerr.Pattern != "/a/:id/b/:id" {
+		t.Errorf("expected a *RegistrationError but got %#v", err)
+	}
+
+	test := htest.New(t, l)
+	test.Get("/users/42").Do().ExpectBody("user")
+	test.Get("/a/1/b/2").Do().ExpectStatus(http.StatusNotFound)
+	if len(l.Routes()) != 1 {
+		t.Errorf("expected only one route but got %v", l.Routes())
+	}
+
+	if _, err := l.TryGroup("api"); err == nil {
+		t.Error("expected an error for a group pattern without a leading slash")
+	}
+	if g, err := l.TryGroup("/api"); err != nil || g == nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTryUseNamed(t *testing.T) {
+	l := New()
+	l.DefineFunc("auth", headerMiddleware("auth"))
+
+	if err := l.Group("/admin").TryUseNamed("auth"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := l.Group("/admin").TryUseNamed("jwt"); err == nil || !strings.Contains(err.Error(), "jwt") {
+		t.Errorf("expected an error for an unknown named middleware but got %v", err)
+	}
+
+	// The middleware defined by the parent is only used by the group
+	api := l.Group("/api")
+	if err := api.TryUseNamed("auth"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	api.Get("/users", fakeHandler())
+	l.Group("/public").Get("/users", fakeHandler())
+
+	test := htest.New(t, l)
+	test.Get("/api/users").Do().ExpectHeader("auth", "mw")
+	test.Get("/public/users").Do().ExpectHeader("auth", "")
+}
+
+// invalidRoutesModule registers valid and invalid routes
+type invalidRoutesModule struct{}
+
+func (invalidRoutesModule) Base() string { return "/plugin" }
+
+func (invalidRoutesModule) Routes(r *Router) {
+	r.Get("/ok", fakeHandlerWithBody("ok"))
+	r.Get("/dup/:id/:id", fakeHandler()).WithName("dup")
+	r.UseNamed("unknown")
+	r.Get("/users/:id", fakeHandlerWithBody("user"))
+	r.Get("/users/:name/posts", fakeHandler())
+}
+
+func TestTryModule(t *testing.T) {
+	l := New()
+	err := l.TryModule(testmodule{"/admin"})
+	if err == nil || !strings.Contains(err.Error(), "/admin") {
+		t.Fatalf("expected an error for the unmet requirements but got %v", err)
+	}
+	if len(l.Routes()) != 0 || len(l.subrouters) != 0 {
+		t.Error("nothing should be registered when the requirements are not met")
+	}
+
+	l.DefineFunc("auth", headerMiddleware("auth"))
+	l.DefineFunc("jwt", headerMiddleware("jwt"))
+
+	// The modules are all checked before registering any of them
+	err = l.TryModule(testmodule{"/ok"}, testmodule{"/bad/:id/:id"})
+	if _, ok := err.(*RegistrationError); !ok || !strings.Contains(err.Error(), "duplicate parameter id") {
+		t.Fatalf("expected an error for the invalid base but got %v", err)
+	}
+	if len(l.Routes()) != 0 {
+		t.Errorf("nothing should be registered when a module is invalid: %v", l.Routes())
+	}
+
+	if err := l.TryModule(testmodule{"/admin"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	htest.New(t, l).Get("/admin").Do().
+		ExpectHeader("auth", "mw").
+		ExpectHeader("jwt", "mw").
+		ExpectBody("getmodule")
+}
+
+func TestTryModuleRoutes(t *testing.T) {
+	l := New()
+	var err error
+	if recv := catchPanic(func() { err = l.TryModule(invalidRoutesModule{}) }); recv != nil {
+		t.Fatalf("TryModule should not panic: %v", recv)
+	}
+
+	rerr, ok := err.(*RegistrationError)
+	if !ok {
+		t.Fatalf("expected a *RegistrationError but got %v", err)
+	}
+	for _, expected := range []RouteIssue{
+		{Kind: IssueInvalid, Pattern: "/plugin/dup/:id/:id"},
+		{Kind: IssueInvalid, Pattern: "/plugin"},
+		{Kind: IssueInvalid, Pattern: "/plugin/users/:name/posts"},
+	} {
+		if !hasIssue(rerr.Issues, expected) {
+			t.Errorf("expected an issue for %s in %v", expected.Pattern, rerr.Issues)
+		}
+	}
+	if len(rerr.Issues) != 3 || !strings.Contains(err.Error(), "/plugin/users/:name/posts: Conflicting parameter name") {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// The valid routes are registered
+	test := htest.New(t, l)
+	test.Get("/plugin/ok").Do().ExpectBody("ok")
+	test.Get("/plugin/users/42").Do().ExpectBody("user")
+	test.Get("/plugin/dup/1/2").Do().ExpectStatus(http.StatusNotFound)
+	if l.Route("dup") != nil {
+		t.Error("the skipped route should not be registered")
+	}
+
+	// Registration errors panic again once TryModule has returned
+	if recv := catchPanic(func() { l.Subrouter().Get("/dup/:id/:id", fakeHandler()) }); recv == nil {
+		t.Error("Should panic after TryModule")
+	}
+	for _, sr := range l.subrouters {
+		if sr.registration != nil {
+			t.Errorf("the subrouter %s should not collect issues anymore", sr.pattern)
+		}
+	}
+}
+
+// headerMiddleware sets the header name to the value mw
+func headerMiddleware(name string) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(name, "mw")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -41,8 +44,160 @@ func wrap(ctxHandler func(Context)) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// wrapE converts an error-returning contextual handler to an http.Handler.
+// Returned errors and recovered panics are passed to the ErrorHandler of the router that matched the request.
+func wrapE(ctxHandler func(Context) error) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		c := C(r)
+		if err := callE(ctxHandler, c); err != nil {
+			errorHandlerFor(c)(c, err)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+func callE(ctxHandler func(Context) error, c Context) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return ctxHandler(c)
+}
+
+func errorHandlerFor(c Context) func(Context, error) {
+	if ctx, ok := c.(*ctx); ok && ctx.router != nil {
+		return ctx.router.handleError()
+	}
+	return defaultErrorHandler
+}
+
 func unwrap(handler http.Handler) func(Context) {
 	return func(c Context) {
 		handler.ServeHTTP(c, c.Request().WithContext(c))
 	}
 }
+
+// mediaRange is a media range of an Accept header such as text/* or application/json
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header and returns its media ranges ordered by preference
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mr := mediaRange{q: 1}
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		if i := strings.IndexByte(mt, '/'); i >= 0 {
+			mr.typ, mr.subtype = mt[:i], mt[i+1:]
+		} else {
+			mr.typ, mr.subtype = mt, "*"
+		}
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// specificity orders media ranges from */* to type/subtype
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.typ == "*":
+		return 0
+	case mr.subtype == "*":
+		return 1
+	case strings.HasPrefix(mr.subtype, "*+"):
+		return 2
+	}
+	return 3
+}
+
+func (mr mediaRange) matches(mediatype string) bool {
+	typ, subtype := mediatype, ""
+	if i := strings.IndexByte(mediatype, '/'); i >= 0 {
+		typ, subtype = mediatype[:i], mediatype[i+1:]
+	}
+	if mr.typ != "*" && mr.typ != typ {
+		return false
+	}
+	// Structured syntax suffixes: application/*+json matches application/vnd.api+json
+	if strings.HasPrefix(mr.subtype, "*+") {
+		return strings.HasSuffix(subtype, mr.subtype[1:])
+	}
+	// A vendor media type such as application/vnd.example.v2+json accepts application/json
+	if i := strings.LastIndexByte(mr.subtype, '+'); i >= 0 && mr.subtype[i+1:] == subtype {
+		return true
+	}
+	return mr.subtype == "*" || mr.subtype == subtype
+}
+
+// negotiateMediaType returns the offer preferred by the Accept header.
+// The q value of an offer is the one of the most specific media range matching it, so that
+// application/json;q=0.1 lowers the preference of JSON in "*/*, application/json;q=0.1" (RFC 7231 section 5.3.2).
+// Offers with the same q value are ordered by the specificity of their range, then by the order of the header and of the offers.
+// The first offer is returned if the header is empty and an empty string if no offer is acceptable.
+func negotiateMediaType(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestRange := "", -1
+	for _, offer := range offers {
+		i := mostSpecificRange(ranges, strings.ToLower(offer))
+		if i < 0 || ranges[i].q <= 0 {
+			continue
+		}
+		if bestRange < 0 || preferredRange(ranges, i, bestRange) {
+			best, bestRange = offer, i
+		}
+	}
+	return best
+}
+
+// mostSpecificRange returns the index of the most specific media range matching mediatype, or -1
+func mostSpecificRange(ranges []mediaRange, mediatype string) int {
+	found := -1
+	for i, mr := range ranges {
+		if mr.matches(mediatype) && (found < 0 || mr.specificity() > ranges[found].specificity()) {
+			found = i
+		}
+	}
+	return found
+}
+
+// preferredRange reports whether the range i is preferred to the range j
+func preferredRange(ranges []mediaRange, i, j int) bool {
+	switch {
+	case ranges[i].q != ranges[j].q:
+		return ranges[i].q > ranges[j].q
+	case ranges[i].specificity() != ranges[j].specificity():
+		return ranges[i].specificity() > ranges[j].specificity()
+	}
+	return i < j
+}
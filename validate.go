@@ -0,0 +1,396 @@
+package lion
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidatorFunc reports whether value satisfies a validation rule.
+// param is the text following '=' in the rule, for example "3" in "min=3".
+type ValidatorFunc func(value reflect.Value, param string) bool
+
+var (
+	validators = map[string]ValidatorFunc{
+		"email": validateEmail,
+		"url":   validateURL,
+		"oneof": validateOneOf,
+	}
+	validatorsMu sync.RWMutex
+)
+
+// RegisterValidator registers a custom rule usable in validate tags. It replaces any rule with the same name.
+// The rules required, omitempty, min, max and len are built in and cannot be replaced.
+func RegisterValidator(name string, fn ValidatorFunc) {
+	switch name {
+	case "required", "omitempty", "min", "max", "len":
+		panicl("cannot replace the built-in validation rule %s", name)
+	}
+	validatorsMu.Lock()
+	validators[name] = fn
+	validatorsMu.Unlock()
+}
+
+// FieldError describes a field that does not satisfy a validation rule
+type FieldError struct {
+	Field   string `json:"field"` // Path of the field such as address.city or items[0].name
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationError is returned by Validate and Bind when a struct does not satisfy its validate tags.
+// It implements HTTPError with a 422 Unprocessable Entity status.
+// Context.Error renders it as a JSON document listing each invalid field:
+//		 {"message":"validation failed","errors":[{"field":"name","rule":"min","param":"3","message":"must be at least 3 characters long"}]}
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, ferr := range e.Errors {
+		msgs[i] = ferr.Error()
+	}
+	return "validation failed: " + strings.Join(msgs, ", ")
+}
+
+// Status returns http.StatusUnprocessableEntity
+func (e *ValidationError) Status() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (e *ValidationError) render(c Context) error {
+	return c.WithStatus(e.Status()).JSON(struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}{"validation failed", e.Errors})
+}
+
+// Validate checks v against the rules declared in its validate tags.
+// v should be a struct or a pointer to a struct. Nested structs, pointers to structs and slices of structs are validated as well.
+// The returned error is nil or a *ValidationError if v does not satisfy its rules.
+// The tags of each struct type are checked once, on first use: an unknown rule or an invalid parameter returns an error
+// which is not a *ValidationError.
+//
+// The following rules are available:
+// 	required	the field must not be the zero value
+// 	omitempty	skip the other rules if the field is the zero value
+// 	min=n		minimum length of a string, slice or map or minimum value of a number
+// 	max=n		maximum length of a string, slice or map or maximum value of a number
+// 	len=n		exact length of a string, slice or map
+// 	email		a valid email address
+// 	url		an absolute url
+// 	oneof=a b	one of the space separated values
+//
+// Example:
+//		 type CreateUser struct {
+//		 	Name  string `json:"name" validate:"required,min=3"`
+//		 	Email string `json:"email" validate:"required,email"`
+//		 	Role  string `json:"role" validate:"omitempty,oneof=admin user"`
+//		 }
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []FieldError
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+// fieldRules are the rules of the validate tag of a struct field
+type fieldRules struct {
+	index int
+	name  string // empty for embedded structs whose fields are validated as fields of the parent
+	rules []rule
+}
+
+type rule struct {
+	name, param string
+	size        float64 // parameter of the min, max and len rules
+}
+
+// structRules caches the rules of each struct type
+var structRules sync.Map // map[reflect.Type][]fieldRules
+
+// rulesFor returns the rules of the fields of the struct type t. The tags are parsed and checked once per type.
+func rulesFor(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := structRules.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous { // unexported
+			continue
+		}
+
+		fr := fieldRules{index: i}
+		if !field.Anonymous {
+			fr.name = fieldName(field)
+		}
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			rules, err := parseRules(field.Type, tag)
+			if err != nil {
+				return nil, fmt.Errorf("lion: invalid validate tag for field %s of %s: %s", field.Name, t, err)
+			}
+			fr.rules = rules
+		}
+		fields = append(fields, fr)
+	}
+
+	// Types with invalid tags are not cached so that rules registered later are taken into account
+	structRules.Store(t, fields)
+	return fields, nil
+}
+
+func parseRules(t reflect.Type, tag string) ([]rule, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var rules []rule
+	for _, r := range strings.Split(tag, ",") {
+		rl := rule{name: r}
+		if i := strings.IndexByte(r, '='); i >= 0 {
+			rl.name, rl.param = r[:i], r[i+1:]
+		}
+
+		switch rl.name {
+		case "required", "omitempty":
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(rl.param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %q for validation rule %s", rl.param, rl.name)
+			}
+			rl.size = n
+
+			switch t.Kind() {
+			case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				if rl.name == "len" {
+					return nil, fmt.Errorf("validation rule len cannot be used on %s", t)
+				}
+			default:
+				return nil, fmt.Errorf("validation rule %s cannot be used on %s", rl.name, t)
+			}
+		default:
+			validatorsMu.RLock()
+			_, ok := validators[rl.name]
+			validatorsMu.RUnlock()
+			if !ok {
+				return nil, fmt.Errorf("unknown validation rule %s", rl.name)
+			}
+		}
+		rules = append(rules, rl)
+	}
+	return rules, nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *[]FieldError) error {
+	fields, err := rulesFor(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, fr := range fields {
+		fv := rv.Field(fr.index)
+		path := prefix
+		if fr.name != "" {
+			path = joinFieldPath(prefix, fr.name)
+		}
+
+		if !validateField(fv, path, fr.rules, errs) {
+			continue
+		}
+		if err := validateNested(fv, path, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested validates structs reachable from fv
+func validateNested(fv reflect.Value, path string, errs *[]FieldError) error {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			return validateNested(fv.Elem(), path, errs)
+		}
+	case reflect.Struct:
+		return validateStruct(fv, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateField applies rules to fv. It returns false if a rule failed.
+func validateField(fv reflect.Value, path string, rules []rule, errs *[]FieldError) bool {
+	for _, rl := range rules {
+		ok, msg := checkRule(fv, rl)
+		if ok == skipRules {
+			return true
+		}
+		if ok == ruleFailed {
+			*errs = append(*errs, FieldError{Field: path, Rule: rl.name, Param: rl.param, Message: msg})
+			return false
+		}
+	}
+	return true
+}
+
+type ruleResult int
+
+const (
+	rulePassed ruleResult = iota
+	ruleFailed
+	skipRules
+)
+
+func checkRule(fv reflect.Value, rl rule) (ruleResult, string) {
+	switch rl.name {
+	case "required":
+		if isZeroValue(fv) {
+			return ruleFailed, "is required"
+		}
+		return rulePassed, ""
+	case "omitempty":
+		if isZeroValue(fv) {
+			return skipRules, ""
+		}
+		return rulePassed, ""
+	case "min", "max", "len":
+		return checkSize(fv, rl)
+	}
+
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return rulePassed, ""
+		}
+		fv = fv.Elem()
+	}
+
+	validatorsMu.RLock()
+	fn := validators[rl.name]
+	validatorsMu.RUnlock()
+	if fn(fv, rl.param) {
+		return rulePassed, ""
+	}
+
+	switch rl.name {
+	case "email":
+		return ruleFailed, "must be a valid email address"
+	case "url":
+		return ruleFailed, "must be a valid url"
+	case "oneof":
+		return ruleFailed, "must be one of [" + rl.param + "]"
+	}
+	return ruleFailed, "does not satisfy " + rl.name
+}
+
+// checkSize checks the min, max and len rules. The kind of fv has been checked by parseRules.
+func checkSize(fv reflect.Value, rl rule) (ruleResult, string) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return rulePassed, ""
+		}
+		fv = fv.Elem()
+	}
+
+	var size float64
+	unit := ""
+	switch fv.Kind() {
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(fv.String())), " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size, unit = float64(fv.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = fv.Float()
+	}
+
+	switch {
+	case rl.name == "min" && size < rl.size:
+		return ruleFailed, "must be at least " + rl.param + unit
+	case rl.name == "max" && size > rl.size:
+		return ruleFailed, "must be at most " + rl.param + unit
+	case rl.name == "len" && size != rl.size:
+		return ruleFailed, "must be exactly " + rl.param + unit
+	}
+	return rulePassed, ""
+}
+
+func isZeroValue(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
+
+func validateEmail(fv reflect.Value, _ string) bool {
+	s := fv.String()
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+func validateURL(fv reflect.Value, _ string) bool {
+	u, err := url.Parse(fv.String())
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func validateOneOf(fv reflect.Value, param string) bool {
+	val := fmt.Sprint(fv.Interface())
+	for _, allowed := range strings.Fields(param) {
+		if val == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName returns the name used in the request for a field: its json, xml, form, query, param or header name
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "xml", "form", "query", "param", "header"} {
+		name := strings.Split(field.Tag.Get(key), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
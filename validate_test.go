@@ -0,0 +1,210 @@
+package lion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateUser struct {
+	Name      string            `json:"name" validate:"required,min=3,max=10"`
+	Email     string            `json:"email" validate:"required,email"`
+	Role      string            `json:"role" validate:"omitempty,oneof=admin user"`
+	Age       int               `json:"age" validate:"min=18"`
+	Website   *string           `json:"website" validate:"omitempty,url"`
+	Tags      []string          `json:"tags" validate:"max=2"`
+	Code      string            `query:"code" validate:"omitempty,len=4"`
+	Address   validateAddress   `json:"address"`
+	Addresses []validateAddress `json:"addresses"`
+}
+
+func TestValidate(t *testing.T) {
+	badURL := "not a url"
+	tests := []struct {
+		name     string
+		input    validateUser
+		expected []FieldError
+	}{
+		{
+			name:  "valid",
+			input: validateUser{Name: "batman", Email: "bruce@wayne.com", Role: "admin", Age: 30, Address: validateAddress{City: "Gotham"}},
+		},
+		{
+			name:  "invalid",
+			input: validateUser{Name: "bo", Email: "bruce", Role: "joker", Age: 12, Website: &badURL, Tags: []string{"a", "b", "c"}, Code: "12345", Addresses: []validateAddress{{City: "Gotham"}, {}}},
+			expected: []FieldError{
+				{Field: "name", Rule: "min", Param: "3", Message: "must be at least 3 characters long"},
+				{Field: "email", Rule: "email", Message: "must be a valid email address"},
+				{Field: "role", Rule: "oneof", Param: "admin user", Message: "must be one of [admin user]"},
+				{Field: "age", Rule: "min", Param: "18", Message: "must be at least 18"},
+				{Field: "website", Rule: "url", Message: "must be a valid url"},
+				{Field: "tags", Rule: "max", Param: "2", Message: "must be at most 2 items"},
+				{Field: "code", Rule: "len", Param: "4", Message: "must be exactly 4 characters long"},
+				{Field: "address.city", Rule: "required", Message: "is required"},
+				{Field: "addresses[1].city", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name:  "required",
+			input: validateUser{Age: 18, Address: validateAddress{City: "Gotham"}},
+			expected: []FieldError{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "email", Rule: "required", Message: "is required"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(&test.input)
+			if test.expected == nil {
+				if err != nil {
+					t.Errorf("Should not error but got %s", err)
+				}
+				return
+			}
+
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Expected a *ValidationError but got %T", err)
+			}
+			if !reflect.DeepEqual(verr.Errors, test.expected) {
+				t.Errorf("Expected %+v but got %+v", test.expected, verr.Errors)
+			}
+		})
+	}
+}
+
+// restoreValidators restores the validator registry at the end of the test and clears the rules cached with it
+func restoreValidators(t *testing.T) {
+	validatorsMu.RLock()
+	saved := make(map[string]ValidatorFunc, len(validators))
+	for name, fn := range validators {
+		saved[name] = fn
+	}
+	validatorsMu.RUnlock()
+
+	t.Cleanup(func() {
+		validatorsMu.Lock()
+		validators = saved
+		validatorsMu.Unlock()
+		structRules.Range(func(key, _ interface{}) bool {
+			structRules.Delete(key)
+			return true
+		})
+	})
+}
+
+func TestRegisterValidator(t *testing.T) {
+	restoreValidators(t)
+	RegisterValidator("even", func(v reflect.Value, _ string) bool {
+		return v.Int()%2 == 0
+	})
+
+	err := Validate(struct {
+		N int `validate:"even"`
+	}{3})
+	if err == nil || err.Error() != "validation failed: N does not satisfy even" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	recv := catchPanic(func() {
+		RegisterValidator("required", nil)
+	})
+	if recv == nil {
+		t.Error("Should panic when replacing a built-in rule")
+	}
+}
+
+func TestValidateInvalidTags(t *testing.T) {
+	tests := []struct {
+		v   interface{}
+		err string
+	}{
+		{struct {
+			N int `validate:"unknown"`
+		}{}, "unknown validation rule unknown"},
+		{struct {
+			S string `validate:"min=three"`
+		}{}, `invalid parameter "three" for validation rule min`},
+		{struct {
+			N int `validate:"len=2"`
+		}{}, "validation rule len cannot be used on int"},
+		{struct {
+			B bool `validate:"max=1"`
+		}{}, "validation rule max cannot be used on bool"},
+		{struct {
+			Items []struct {
+				N int `validate:"unknown"`
+			}
+		}{Items: make([]struct {
+			N int `validate:"unknown"`
+		}, 1)}, "unknown validation rule unknown"},
+	}
+
+	for _, test := range tests {
+		var err error
+		if recv := catchPanic(func() { err = Validate(test.v) }); recv != nil {
+			t.Errorf("Validate should not panic: %v", recv)
+			continue
+		}
+		if _, ok := err.(*ValidationError); ok || err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected an error containing %q but got %v", test.err, err)
+		}
+	}
+
+	// A rule registered after a failed validation is taken into account
+	restoreValidators(t)
+	type later struct {
+		S string `validate:"later"`
+	}
+	if err := Validate(later{}); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+	RegisterValidator("later", func(reflect.Value, string) bool { return true })
+	if err := Validate(later{}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestBindValidation(t *testing.T) {
+	l := New()
+	l.Post("/users", wrap(func(c Context) {
+		var u validateUser
+		if err := Bind(c, &u); err != nil {
+			c.Error(err)
+			return
+		}
+		c.String("%s", u.Name)
+	}))
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"batman","email":"bruce","age":30,"address":{"city":"Gotham"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d but got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != contentTypeJSON {
+		t.Errorf("Expected content type %s but got %s", contentTypeJSON, got)
+	}
+	want := `{"message":"validation failed","errors":[{"field":"email","rule":"email","message":"must be a valid email address"}]}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected '%s' but got '%s'", want, got)
+	}
+
+	req = httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"batman","email":"bruce@wayne.com","age":30,"address":{"city":"Gotham"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "batman" {
+		t.Errorf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
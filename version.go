@@ -0,0 +1,155 @@
+package lion
+
+import (
+	"net/http"
+	"strings"
+)
+
+// VersioningConfig configures how the API version of a request is determined
+type VersioningConfig struct {
+	// Default is the version used when the request does not specify one.
+	// If empty, such requests receive a 406 Not Acceptable response from versioned routes that have no handler without a version.
+	Default string
+	// Header is the header containing the version such as "2" or "v2". Defaults to Accept-Version.
+	Header string
+	// Vendor restricts versions in the Accept header to a vendor tree such as "vnd.example" for application/vnd.example.v2+json.
+	// If empty, any vendor tree is accepted.
+	Vendor string
+}
+
+func (cfg VersioningConfig) header() string {
+	if cfg.Header == "" {
+		return "Accept-Version"
+	}
+	return cfg.Header
+}
+
+// Versioning sets how versioned routes registered on this router and its subrouters determine the version of a request.
+func (r *Router) Versioning(cfg VersioningConfig) {
+	r.versioning = &cfg
+}
+
+func (r *Router) versioningConfig() VersioningConfig {
+	if rr := r.nearest(func(rr *Router) bool { return rr.versioning != nil }); rr != nil {
+		return *rr.versioning
+	}
+	return VersioningConfig{}
+}
+
+// Version returns a subrouter whose routes only match requests for the given API version.
+// The version of a request is taken from the Accept-Version header (Accept-Version: 2)
+// or from a vendor media type in the Accept header (Accept: application/vnd.example.v2+json).
+// Otherwise, the default version set with Router.Versioning is used.
+//
+// This allows to serve several versions of the same path side by side:
+// 	l.Versioning(lion.VersioningConfig{Default: "1"})
+// 	l.Version("1").Get("/users", usersV1)
+// 	l.Version("2").Get("/users", usersV2)
+// Requests for a version that has no handler receive a 406 Not Acceptable response.
+// So do requests that do not specify a version when no default version is set.
+// Responses of versioned routes have a Vary header so that caches take the version into account.
+//
+// A Module can declare its version with a Version() string method.
+func (r *Router) Version(version string) *Router {
+	nr := r.Subrouter()
+	nr.version = normalizeVersion(version)
+	return nr
+}
+
+// apiVersion returns the closest version walking up the router's parents
+func (r *Router) apiVersion() string {
+	if rr := r.nearest(func(rr *Router) bool { return rr.version != "" }); rr != nil {
+		return rr.version
+	}
+	return ""
+}
+
+// moduleVersion allows a Module to declare its API version
+type moduleVersion interface {
+	Version() string
+}
+
+// versionPredicate matches requests for version using the versioning configuration of router
+func versionPredicate(router *Router, version string) predicate {
+	return predicate{
+		kind: predicateVersion,
+		match: func(req *http.Request) bool {
+			return requestVersion(router.versioningConfig(), req) == version
+		},
+	}
+}
+
+// requestVersion returns the API version requested
+func requestVersion(cfg VersioningConfig, req *http.Request) string {
+	if v := req.Header.Get(cfg.header()); v != "" {
+		return normalizeVersion(v)
+	}
+
+	for _, mr := range parseAccept(req.Header.Get("Accept")) {
+		if v, ok := vendorVersion(cfg.Vendor, mr.subtype); ok {
+			return v
+		}
+	}
+
+	return normalizeVersion(cfg.Default)
+}
+
+// vendorVersion extracts the version of a vendor subtype such as vnd.example.v2+json.
+// The version is the first element of the subtype of the form v<digits> following the vendor tree, as in vnd.example.v3.raw.
+func vendorVersion(vendor, subtype string) (string, bool) {
+	if i := strings.IndexByte(subtype, '+'); i >= 0 {
+		subtype = subtype[:i]
+	}
+	if !strings.HasPrefix(subtype, "vnd.") {
+		return "", false
+	}
+
+	parts := strings.Split(subtype, ".")
+	for i := 2; i < len(parts); i++ {
+		if !isVersionElement(parts[i]) {
+			continue
+		}
+		if vendor != "" && strings.Join(parts[:i], ".") != strings.ToLower(vendor) {
+			return "", false
+		}
+		return parts[i][1:], true
+	}
+	return "", false
+}
+
+// isVersionElement reports whether s is of the form v<digits>
+func isVersionElement(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeVersion(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) > 1 && (v[0] == 'v' || v[0] == 'V') {
+		v = v[1:]
+	}
+	return v
+}
+
+// varyHandler adds the headers used to determine the version to the Vary header
+type varyHandler struct {
+	router *Router
+	next   http.Handler
+}
+
+func (h varyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	addVersionVary(w, h.router)
+	h.next.ServeHTTP(w, req)
+}
+
+func addVersionVary(w http.ResponseWriter, router *Router) {
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", router.versioningConfig().header())
+}
@@ -0,0 +1,111 @@
+package lion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type usersModule struct {
+	version string
+}
+
+func (m usersModule) Base() string    { return "/users" }
+func (m usersModule) Version() string { return m.version }
+func (m usersModule) Routes(r *Router) {
+	r.GET("/:id", func(c Context) {
+		c.String("user %s v%s", c.Param("id"), m.version)
+	})
+}
+
+func (m usersModule) Get(c Context) {
+	Negotiate(c, []string{"users v" + m.version})
+}
+
+func TestVersioning(t *testing.T) {
+	l := New()
+	l.Versioning(VersioningConfig{Default: "1", Vendor: "vnd.lion"})
+	l.Module(usersModule{"1"}, usersModule{"2"})
+
+	l.Version("2").Get("/status", fakeHandlerWithBody("status v2"))
+	l.Get("/health", fakeHandlerWithBody("ok"))
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{path: "/users/42", body: "user 42 v1"},
+		{path: "/users/42", headers: map[string]string{"Accept-Version": "2"}, body: "user 42 v2"},
+		{path: "/users/42", headers: map[string]string{"Accept-Version": "v1"}, body: "user 42 v1"},
+		{path: "/users/42", headers: map[string]string{"Accept": "application/vnd.lion.v2+json"}, body: "user 42 v2"},
+		{path: "/users/42", headers: map[string]string{"Accept": "application/vnd.other.v2+json"}, body: "user 42 v1"},
+		{path: "/users/42", headers: map[string]string{"Accept-Version": "3"}, status: http.StatusNotAcceptable},
+		{path: "/users", headers: map[string]string{"Accept": "application/vnd.lion.v2+json"}, body: `["users v2"]`},
+		{path: "/status", status: http.StatusNotAcceptable},
+		{path: "/status", headers: map[string]string{"Accept-Version": "2"}, body: "status v2"},
+		{path: "/health", headers: map[string]string{"Accept-Version": "3"}, body: "ok"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+
+		status := http.StatusOK
+		if test.status != 0 {
+			status = test.status
+		}
+		if w.Code != status {
+			t.Errorf("%s %v: expected status %d but got %d", test.path, test.headers, status, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %v: expected body '%s' but got '%s'", test.path, test.headers, test.body, w.Body.String())
+		}
+		if test.path != "/health" {
+			if vary := w.Header()["Vary"]; !containsString(vary, "Accept-Version") {
+				t.Errorf("%s %v: unexpected Vary header %v", test.path, test.headers, vary)
+			}
+		}
+	}
+}
+
+func TestVendorVersion(t *testing.T) {
+	tests := []struct {
+		vendor, subtype string
+		version         string
+		ok              bool
+	}{
+		{"", "vnd.example.v2+json", "2", true},
+		{"", "vnd.example.v3.raw", "3", true},
+		{"", "vnd.example.v3.raw+json", "3", true},
+		{"vnd.example", "vnd.example.v3.raw", "3", true},
+		{"vnd.example", "vnd.other.v3", "", false},
+		{"", "vnd.example.api.v10+xml", "10", true},
+		{"vnd.example.api", "vnd.example.api.v10+xml", "10", true},
+		{"", "vnd.example.vendor+json", "", false},
+		{"", "vnd.example.v+json", "", false},
+		{"", "vnd.v2+json", "", false},
+		{"", "json", "", false},
+	}
+
+	for _, test := range tests {
+		version, ok := vendorVersion(test.vendor, test.subtype)
+		if version != test.version || ok != test.ok {
+			t.Errorf("%q with vendor %q: expected (%q, %t) but got (%q, %t)", test.subtype, test.vendor, test.version, test.ok, version, ok)
+		}
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}